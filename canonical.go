@@ -0,0 +1,245 @@
+package structpb
+
+import (
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MarshalCanonicalJSON returns the JSON Canonicalization Scheme
+// (RFC 8785) encoding of x, suitable for signing and hashing: Dict keys
+// are sorted by their UTF-16 code units, there is no insignificant
+// whitespace, strings use the minimal escaping and numbers the shortest
+// ECMAScript representation of a float64.
+//
+// Non-finite floats cannot be represented and are reported as an error,
+// as are IntValues and integral DecimalValues that a float64 cannot
+// represent exactly, such as 2^53 + 1, unless CanonicalOptions.ExactIntegers
+// is set. Other DecimalValues are rounded to the nearest float64.
+// BytesValue, TimestampValue and DurationValue are written as strings,
+// like MarshalOptions.Marshal does.
+func MarshalCanonicalJSON(x *Value) ([]byte, error) {
+	return CanonicalOptions{}.Marshal(x)
+}
+
+// MarshalCanonicalJSON returns the canonical JSON encoding of x,
+// see the package level MarshalCanonicalJSON.
+func (x *Dict) MarshalCanonicalJSON() ([]byte, error) {
+	return CanonicalOptions{}.Marshal(NewStructValue(x))
+}
+
+// MarshalCanonicalJSON returns the canonical JSON encoding of x,
+// see the package level MarshalCanonicalJSON.
+func (x *List) MarshalCanonicalJSON() ([]byte, error) {
+	return CanonicalOptions{}.Marshal(NewListValue(x))
+}
+
+// Hash writes the canonical JSON encoding of v to h and returns the
+// resulting digest, so semantically identical Values always produce the
+// same digest regardless of Dict key order. h is not reset first.
+func Hash(v *Value, h hash.Hash) ([]byte, error) {
+	return CanonicalOptions{}.Hash(v, h)
+}
+
+// CanonicalOptions configures the canonical JSON encoding.
+type CanonicalOptions struct {
+	// ExactIntegers writes integers beyond ±2^53 with all their digits,
+	// rather than as the shortest ECMAScript representation of a float64,
+	// which drops digits, or failing when a float64 cannot represent them
+	// exactly. The output is then not canonical per RFC 8785 for these
+	// integers.
+	ExactIntegers bool
+}
+
+// Marshal returns the canonical JSON encoding of x, see the package
+// level MarshalCanonicalJSON.
+func (o CanonicalOptions) Marshal(x *Value) ([]byte, error) {
+	return o.appendCanonical(nil, x)
+}
+
+// Hash writes the canonical JSON encoding of v to h and returns the
+// resulting digest, see the package level Hash.
+func (o CanonicalOptions) Hash(v *Value, h hash.Hash) ([]byte, error) {
+	b, err := o.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.Write(b); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// maxExactInt is 2^53, the integers up to it are safe in ECMAScript.
+var maxExactInt = big.NewInt(1 << 53)
+
+// appendInteger writes i, which must be exactly representable as a
+// float64 unless o.ExactIntegers is set.
+func (o CanonicalOptions) appendInteger(b []byte, i *big.Int) ([]byte, error) {
+	if o.ExactIntegers && i.CmpAbs(maxExactInt) > 0 {
+		return i.Append(b, 10), nil
+	}
+	f, acc := new(big.Float).SetInt(i).Float64()
+	if acc == big.Exact {
+		if f == 0 {
+			return append(b, '0'), nil
+		}
+		return appendES6Float(b, f), nil
+	}
+	return nil, fmt.Errorf("cannot canonicalize integer %s, a float64 cannot represent it exactly", i)
+}
+
+func (o CanonicalOptions) appendCanonical(b []byte, x *Value) ([]byte, error) {
+	switch v := x.GetKind().(type) {
+	case *Value_BoolValue:
+		return strconv.AppendBool(b, v.BoolValue), nil
+	case *Value_IntValue:
+		return o.appendInteger(b, big.NewInt(v.IntValue))
+	case *Value_FloatValue:
+		if math.IsNaN(v.FloatValue) || math.IsInf(v.FloatValue, 0) {
+			return nil, fmt.Errorf("cannot canonicalize non-finite float %v", v.FloatValue)
+		}
+		if v.FloatValue == 0 {
+			// -0 is written as 0
+			return append(b, '0'), nil
+		}
+		return appendES6Float(b, v.FloatValue), nil
+	case *Value_DecimalValue:
+		r := numberRat(x)
+		if r == nil {
+			return nil, fmt.Errorf("invalid DecimalValue %q", v.DecimalValue)
+		}
+		if r.IsInt() {
+			return o.appendInteger(b, r.Num())
+		}
+		f, _ := r.Float64()
+		if math.IsInf(f, 0) {
+			return nil, fmt.Errorf("cannot canonicalize DecimalValue %s beyond float64 range", v.DecimalValue)
+		}
+		if f == 0 {
+			return append(b, '0'), nil
+		}
+		return appendES6Float(b, f), nil
+	case *Value_StringValue:
+		return appendCanonicalString(b, v.StringValue)
+	case *Value_BytesValue:
+		return appendCanonicalString(b, base64.StdEncoding.EncodeToString(v.BytesValue))
+	case *Value_TimestampValue:
+		if err := v.TimestampValue.CheckValid(); err != nil {
+			return nil, err
+		}
+		return appendCanonicalString(b, formatTimestamp(v.TimestampValue))
+	case *Value_DurationValue:
+		if err := v.DurationValue.CheckValid(); err != nil {
+			return nil, err
+		}
+		return appendCanonicalString(b, formatDuration(v.DurationValue))
+	case *Value_ListValue:
+		b = append(b, '[')
+		for i, e := range v.ListValue.GetValues() {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			var err error
+			if b, err = o.appendCanonical(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ']'), nil
+	case *Value_DictValue:
+		keys := v.DictValue.sortedKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+		b = append(b, '{')
+		for i, k := range keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			var err error
+			if b, err = appendCanonicalString(b, k); err != nil {
+				return nil, err
+			}
+			b = append(b, ':')
+			if b, err = o.appendCanonical(b, v.DictValue.Fields[k]); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, '}'), nil
+	default:
+		return append(b, "null"...), nil
+	}
+}
+
+// lessUTF16 compares strings by their UTF-16 code units, which differs
+// from the byte-wise order for characters above U+FFFF.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// appendES6Float formats f like ECMAScript Number.prototype.toString.
+func appendES6Float(b []byte, f float64) []byte {
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendCanonicalString quotes s escaping only '"', '\\' and control characters.
+func appendCanonicalString(b []byte, s string) ([]byte, error) {
+	if !utf8.ValidString(s) {
+		return nil, fmt.Errorf("invalid UTF-8 in string: %q", s)
+	}
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' {
+			continue
+		}
+		b = append(b, s[start:i]...)
+		switch c {
+		case '"', '\\':
+			b = append(b, '\\', c)
+		case '\b':
+			b = append(b, '\\', 'b')
+		case '\f':
+			b = append(b, '\\', 'f')
+		case '\n':
+			b = append(b, '\\', 'n')
+		case '\r':
+			b = append(b, '\\', 'r')
+		case '\t':
+			b = append(b, '\\', 't')
+		default:
+			b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+		}
+		start = i + 1
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"'), nil
+}
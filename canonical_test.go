@@ -0,0 +1,160 @@
+package structpb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+func TestMarshalCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"whitespace", ` { "b" : [ 1 , 2 ] , "a" : { } } `, `{"a":{},"b":[1,2]}`},
+		// RFC 8785, section 3.2.3
+		{"utf-16 key order", `{"\u20ac":"Euro Sign","\r":"Carriage Return","\ufb33":"Hebrew Letter Dalet With Dagesh","1":"One","\ud83d\ude00":"Emoji: Grinning Face","\u0080":"Control","\u00f6":"Latin Small Letter O With Diaeresis"}`,
+			"{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001F600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"},
+		{"string escapes", `"\u20ac$\u000f\u000aA'\u0042\u0022\u005c\\\"\/<>"`, "\"\u20ac$\\u000f\\nA'B\\\"\\\\\\\\\\\"/<>\""},
+		{"literals", `[null,true,false]`, `[null,true,false]`},
+		{"max safe int", `9007199254740992`, `9007199254740992`},
+		{"exact big int", `4611686018427387904`, `4611686018427388000`},
+		{"negative zero", `-0.0`, `0`},
+		{"exponent", `1e21`, `1e+21`},
+		{"small", `0.000001`, `0.000001`},
+		{"smaller", `0.0000001`, `1e-7`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := MarshalCanonicalJSON(mustParse(t, tt.in))
+			if err != nil {
+				t.Fatalf("MarshalCanonicalJSON: %v", err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("MarshalCanonicalJSON = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+// TestCanonicalNumbers runs the number serialization examples of
+// RFC 8785, appendix B.
+func TestCanonicalNumbers(t *testing.T) {
+	tests := []struct {
+		bits uint64
+		want string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555554, "333333333.33333325"},
+		{0x41b3de4355555555, "333333333.3333333"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0x41b3de4355555557, "333333333.33333343"},
+		{0xbecbf647612f3696, "-0.0000033333333333333333"},
+		{0x43143ff3c1cb0959, "1424953923781206.2"},
+	}
+	for _, tt := range tests {
+		b, err := MarshalCanonicalJSON(NewFloatValue(math.Float64frombits(tt.bits)))
+		if err != nil {
+			t.Errorf("MarshalCanonicalJSON(%#x): %v", tt.bits, err)
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("MarshalCanonicalJSON(%#x) = %s, want %s", tt.bits, b, tt.want)
+		}
+	}
+}
+
+func TestCanonicalIntegers(t *testing.T) {
+	dec := func(s string) *Value {
+		v, err := NewDecimalValue(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name  string
+		in    *Value
+		want  string // "" if an error is expected
+		exact string // with ExactIntegers
+	}{
+		{"safe", NewIntValue(-(1 << 53)), `-9007199254740992`, `-9007199254740992`},
+		{"power of two", NewIntValue(1 << 62), `4611686018427388000`, `4611686018427387904`},
+		{"not a float64", NewIntValue(1<<53 + 1), ``, `9007199254740993`},
+		{"max int64", NewIntValue(math.MaxInt64), ``, `9223372036854775807`},
+		{"decimal", dec("1e21"), `1e+21`, `1000000000000000000000`},
+		{"huge decimal", dec("123456789012345678901234567890"), ``, `123456789012345678901234567890`},
+		{"fractional decimal", dec("0.1"), `0.1`, `0.1`},
+	}
+	for _, tt := range tests {
+		b, err := MarshalCanonicalJSON(tt.in)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s: MarshalCanonicalJSON = %s, want an error", tt.name, b)
+		case tt.want != "" && (err != nil || string(b) != tt.want):
+			t.Errorf("%s: MarshalCanonicalJSON = %s, %v, want %s", tt.name, b, err, tt.want)
+		}
+		b, err = CanonicalOptions{ExactIntegers: true}.Marshal(tt.in)
+		if err != nil || string(b) != tt.exact {
+			t.Errorf("%s: Marshal with ExactIntegers = %s, %v, want %s", tt.name, b, err, tt.exact)
+		}
+	}
+}
+
+func TestCanonicalErrors(t *testing.T) {
+	for _, v := range []*Value{
+		NewFloatValue(math.NaN()),
+		NewFloatValue(math.Inf(1)),
+		NewStringValue("\xff"),
+		NewListValue(&List{Values: []*Value{NewFloatValue(math.Inf(-1))}}),
+	} {
+		if b, err := MarshalCanonicalJSON(v); err == nil {
+			t.Errorf("MarshalCanonicalJSON(%v) = %s, want an error", v, b)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	a := mustParse(t, `{"b":[1,2.5],"a":"x"}`)
+	b := NewOrderedDict()
+	b.Set("a", NewStringValue("x"))
+	b.Set("b", mustParse(t, `[1,2.5]`))
+
+	ha, err := Hash(a, sha256.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb, err := Hash(NewStructValue(b), sha256.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(ha) != hex.EncodeToString(hb) {
+		t.Errorf("Hash differs with key order: %x and %x", ha, hb)
+	}
+	want := sha256.Sum256([]byte(`{"a":"x","b":[1,2.5]}`))
+	if hex.EncodeToString(ha) != hex.EncodeToString(want[:]) {
+		t.Errorf("Hash = %x, want the digest of the canonical JSON %x", ha, want)
+	}
+	if _, err := Hash(NewIntValue(1<<53+1), sha256.New()); err == nil {
+		t.Error("Hash of an inexact integer succeeded")
+	}
+}
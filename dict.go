@@ -2,6 +2,7 @@ package structpb
 
 import (
 	"google.golang.org/protobuf/runtime/protoimpl"
+	"sort"
 	"unicode/utf8"
 )
 
@@ -9,6 +10,12 @@ func NewEmptyDict() *Dict {
 	return &Dict{Fields: map[string]*Value{}}
 }
 
+// NewOrderedDict constructs an empty Dict which keeps its keys in
+// insertion order.
+func NewOrderedDict() *Dict {
+	return &Dict{Fields: map[string]*Value{}, Ordered: true}
+}
+
 // NewDict constructs a Struct from a general-purpose Go map.
 // The map keys must be valid UTF-8.
 // The map values are converted using NewValue.
@@ -36,6 +43,7 @@ func (x *Dict) Get(key string) *Value {
 }
 
 // Set equals x.Fields[key] = value but is more safe
+// and appends new keys to the key order of an ordered Dict
 // return value is false only if struct == nil
 func (x *Dict) Set(key string, value *Value) bool {
 	if x == nil {
@@ -44,12 +52,110 @@ func (x *Dict) Set(key string, value *Value) bool {
 
 	if x.Fields == nil {
 		x.Fields = map[string]*Value{key: value}
-	} else {
-		x.Fields[key] = value
+		if x.Ordered {
+			x.KeyOrder = append(x.KeyOrder[:0], key)
+		}
+		return true
+	}
+
+	if _, ok := x.Fields[key]; !ok && x.Ordered {
+		if len(x.KeyOrder) > len(x.Fields) {
+			// keys were deleted from Fields directly, drop them before
+			// KeyOrder grows with every delete and Set
+			x.KeyOrder = x.orderedKeys(nil)
+		}
+		x.KeyOrder = append(x.KeyOrder, key)
 	}
+	x.Fields[key] = value
 	return true
 }
 
+// Delete removes key from x, and reports whether it was present
+func (x *Dict) Delete(key string) bool {
+	if x == nil {
+		return false
+	}
+	if _, ok := x.Fields[key]; !ok {
+		return false
+	}
+	delete(x.Fields, key)
+	if x.Ordered {
+		order := x.KeyOrder[:0]
+		for _, k := range x.KeyOrder {
+			if k != key {
+				order = append(order, k)
+			}
+		}
+		x.KeyOrder = order
+	}
+	return true
+}
+
+// Keys returns the keys of x, in insertion order if x is ordered and in
+// lexical order otherwise.
+//
+// The order of an ordered Dict is kept by Set and Delete, editing Fields
+// directly does not update KeyOrder: keys added this way come last in
+// lexical order, deleted keys are skipped, and a key listed more than
+// once in KeyOrder, for example when it is deleted directly and added
+// back with Set or by proto.Merge, is at its last position.
+func (x *Dict) Keys() []string {
+	if !x.GetOrdered() {
+		return x.sortedKeys()
+	}
+
+	seen := make(map[string]bool, len(x.Fields))
+	keys := x.orderedKeys(seen)
+	if len(keys) == len(x.Fields) {
+		return keys
+	}
+	rest := make([]string, 0, len(x.Fields)-len(keys))
+	for k := range x.Fields {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// orderedKeys returns the keys of KeyOrder that are in Fields, each at
+// its last position, and records them in seen if it is not nil.
+func (x *Dict) orderedKeys(seen map[string]bool) []string {
+	if seen == nil {
+		seen = make(map[string]bool, len(x.Fields))
+	}
+	keys := make([]string, 0, len(x.Fields))
+	for i := len(x.KeyOrder) - 1; i >= 0; i-- {
+		k := x.KeyOrder[i]
+		if _, ok := x.Fields[k]; ok && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+	return keys
+}
+
+// Range calls f for each key and value of x, in the order of Keys,
+// and stops if f returns false.
+func (x *Dict) Range(f func(key string, value *Value) bool) {
+	for _, k := range x.Keys() {
+		if !f(k, x.Fields[k]) {
+			return
+		}
+	}
+}
+
+// replace replaces the content of x with the content of d, sharing it.
+func (x *Dict) replace(d *Dict) {
+	x.Fields = d.GetFields()
+	x.KeyOrder = d.GetKeyOrder()
+	x.Ordered = d.GetOrdered()
+}
+
 // AsMap converts x to a general-purpose Go map.
 // The map values are converted by calling Value.AsInterface.
 func (x *Dict) AsMap() map[string]interface{} {
@@ -59,3 +165,13 @@ func (x *Dict) AsMap() map[string]interface{} {
 	}
 	return vs
 }
+
+// sortedKeys returns the keys of x in lexical order
+func (x *Dict) sortedKeys() []string {
+	keys := make([]string, 0, len(x.GetFields()))
+	for k := range x.GetFields() {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+package structpb
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func newOrdered(keys ...string) *Dict {
+	d := NewOrderedDict()
+	for i, k := range keys {
+		d.Set(k, NewIntValue(int64(i)))
+	}
+	return d
+}
+
+func TestOrderedDictKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(d *Dict)
+		want []string
+	}{
+		{"insertion order", func(d *Dict) {}, []string{"c", "a", "b"}},
+		{"set existing keeps position", func(d *Dict) { d.Set("c", NewNullValue()) }, []string{"c", "a", "b"}},
+		{"delete", func(d *Dict) { d.Delete("a") }, []string{"c", "b"}},
+		{"delete and set", func(d *Dict) { d.Delete("c"); d.Set("c", NewNullValue()) }, []string{"a", "b", "c"}},
+		{"direct add", func(d *Dict) { d.Fields["z"] = NewNullValue(); d.Fields["d"] = NewNullValue() }, []string{"c", "a", "b", "d", "z"}},
+		{"direct delete", func(d *Dict) { delete(d.Fields, "a") }, []string{"c", "b"}},
+		{"direct delete and set", func(d *Dict) { delete(d.Fields, "c"); d.Set("c", NewNullValue()) }, []string{"a", "b", "c"}},
+		{"merge", func(d *Dict) { proto.Merge(d, newOrdered("b", "x")) }, []string{"c", "a", "b", "x"}},
+	}
+	for _, tt := range tests {
+		d := newOrdered("c", "a", "b")
+		tt.edit(d)
+		if got := d.Keys(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Keys = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOrderedDictKeyOrderBounded(t *testing.T) {
+	d := newOrdered("a", "b")
+	for i := 0; i < 100; i++ {
+		delete(d.Fields, "a")
+		d.Set("a", NewNullValue())
+	}
+	if len(d.KeyOrder) > 2*len(d.Fields) {
+		t.Errorf("KeyOrder grew to %d entries for %d fields", len(d.KeyOrder), len(d.Fields))
+	}
+	if got := d.Keys(); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("Keys = %q", got)
+	}
+}
+
+func TestUnorderedDictKeys(t *testing.T) {
+	d := NewEmptyDict()
+	for _, k := range []string{"c", "a", "b"} {
+		d.Set(k, NewNullValue())
+	}
+	if got := d.Keys(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("Keys = %q", got)
+	}
+	if d.KeyOrder != nil {
+		t.Errorf("unordered Dict has KeyOrder %q", d.KeyOrder)
+	}
+}
+
+func TestOrderedDictRange(t *testing.T) {
+	d := newOrdered("c", "a", "b")
+	var keys []string
+	d.Range(func(k string, v *Value) bool {
+		keys = append(keys, k)
+		return k != "a"
+	})
+	if !reflect.DeepEqual(keys, []string{"c", "a"}) {
+		t.Errorf("Range visited %q", keys)
+	}
+}
+
+func TestOrderedDictJSON(t *testing.T) {
+	const in = `{"z":1,"a":{"y":2,"b":3},"m":[{"q":1,"p":2}]}`
+	d := &Dict{}
+	if err := (UnmarshalOptions{PreserveKeyOrder: true}).Unmarshal([]byte(in), d); err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, d); got != in {
+		t.Errorf("ordered round trip = %s, want %s", got, in)
+	}
+	if b, _ := (MarshalOptions{SortKeys: true}).Marshal(d); string(b) != `{"a":{"b":3,"y":2},"m":[{"p":2,"q":1}],"z":1}` {
+		t.Errorf("Marshal with SortKeys = %s", b)
+	}
+
+	// unmarshalling into an ordered Dict preserves the order
+	o := NewOrderedDict()
+	if err := o.UnmarshalJSON([]byte(in)); err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, o); got != in {
+		t.Errorf("UnmarshalJSON into an ordered Dict = %s, want %s", got, in)
+	}
+
+	// the order survives the protobuf wire format and Clone
+	b, err := proto.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &Dict{}
+	if err := proto.Unmarshal(b, w); err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, w); got != in {
+		t.Errorf("wire round trip = %s, want %s", got, in)
+	}
+	if got := toJSON(t, d.Clone()); got != in {
+		t.Errorf("Clone = %s, want %s", got, in)
+	}
+}
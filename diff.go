@@ -0,0 +1,198 @@
+package structpb
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind is the kind of a Change reported by Diff.
+type ChangeKind int
+
+const (
+	// ChangeAdded is a value present only in the new Value.
+	ChangeAdded ChangeKind = iota + 1
+	// ChangeRemoved is a value present only in the old Value.
+	ChangeRemoved
+	// ChangeModified is a value of the same kind but different content.
+	ChangeModified
+	// ChangeTypeChanged is a value whose kind changed, including a change
+	// between IntValue and FloatValue.
+	ChangeTypeChanged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	case ChangeTypeChanged:
+		return "type-changed"
+	default:
+		return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// A Change is a single difference between two Values.
+type Change struct {
+	// Path is the JSON Pointer of the changed value.
+	Path string
+	Kind ChangeKind
+	// Old is the old value, nil for ChangeAdded.
+	Old *Value
+	// New is the new value, nil for ChangeRemoved.
+	New *Value
+}
+
+// Changes is the list of differences returned by Diff.
+type Changes []Change
+
+// Diff returns the differences between a and b, ordered by path.
+//
+// Dicts are compared field by field, recursively, so only the innermost
+// differing values are reported. Lists are aligned with the same shortest
+// edit script as CreatePatch: elements are removed, added, or compared in
+// place, and the index in the path of each change is the one of the
+// matching patch operation, that is the position in the List once the
+// previous changes are applied.
+// Values of different kinds, such as IntValue(1) and FloatValue(1),
+// are reported as ChangeTypeChanged.
+func Diff(a, b *Value) Changes {
+	var c Changes
+	return appendChanges(c, "", a, b)
+}
+
+func appendChanges(c Changes, path string, a, b *Value) Changes {
+	if valuesEqual(a, b) {
+		return c
+	}
+
+	switch av := a.GetKind().(type) {
+	case *Value_DictValue:
+		bv, ok := b.GetKind().(*Value_DictValue)
+		if !ok {
+			break
+		}
+		keys := av.DictValue.sortedKeys()
+		for _, k := range bv.DictValue.sortedKeys() {
+			if _, ok := av.DictValue.GetFields()[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			old, inOld := av.DictValue.GetFields()[k]
+			cur, inNew := bv.DictValue.GetFields()[k]
+			switch {
+			case !inNew:
+				c = append(c, Change{Path: appendPointer(path, k), Kind: ChangeRemoved, Old: cloneValue(old)})
+			case !inOld:
+				c = append(c, Change{Path: appendPointer(path, k), Kind: ChangeAdded, New: cloneValue(cur)})
+			default:
+				c = appendChanges(c, appendPointer(path, k), old, cur)
+			}
+		}
+		return c
+	case *Value_ListValue:
+		bv, ok := b.GetKind().(*Value_ListValue)
+		if !ok {
+			break
+		}
+		editList(av.ListValue.GetValues(), bv.ListValue.GetValues(), func(op listEditOp, index int, x, y *Value) {
+			at := appendPointer(path, strconv.Itoa(index))
+			switch op {
+			case listEditPatch:
+				c = appendChanges(c, at, x, y)
+			case listEditRemove:
+				c = append(c, Change{Path: at, Kind: ChangeRemoved, Old: cloneValue(x)})
+			case listEditAdd:
+				c = append(c, Change{Path: at, Kind: ChangeAdded, New: cloneValue(y)})
+			}
+		})
+		return c
+	}
+
+	kind := ChangeModified
+	if kindName(a) != kindName(b) && !(isNullValue(a) && isNullValue(b)) {
+		kind = ChangeTypeChanged
+	}
+	return append(c, Change{Path: path, Kind: kind, Old: cloneValue(a), New: cloneValue(b)})
+}
+
+// Text renders the changes as a unified text report, with one hunk per
+// change headed by its path and kind, followed by the old value prefixed
+// with "-" and the new value prefixed with "+", both in compact JSON.
+func (c Changes) Text() string {
+	var b strings.Builder
+	for _, ch := range c {
+		path := ch.Path
+		if path == "" {
+			path = "/"
+		}
+		switch ch.Kind {
+		case ChangeTypeChanged:
+			fmt.Fprintf(&b, "@@ %s %s: %s -> %s @@\n", path, ch.Kind, kindName(ch.Old), kindName(ch.New))
+		default:
+			fmt.Fprintf(&b, "@@ %s %s @@\n", path, ch.Kind)
+		}
+		if ch.Old != nil {
+			fmt.Fprintf(&b, "-%s\n", changeValueText(ch.Old))
+		}
+		if ch.New != nil {
+			fmt.Fprintf(&b, "+%s\n", changeValueText(ch.New))
+		}
+	}
+	return b.String()
+}
+
+func (c Changes) String() string {
+	return c.Text()
+}
+
+func changeValueText(v *Value) string {
+	b, err := v.MarshalJSON()
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return string(b)
+}
+
+type changeJSON struct {
+	Path    string          `json:"path"`
+	Kind    string          `json:"kind"`
+	OldKind string          `json:"old_kind,omitempty"`
+	NewKind string          `json:"new_kind,omitempty"`
+	Old     json.RawMessage `json:"old,omitempty"`
+	New     json.RawMessage `json:"new,omitempty"`
+}
+
+// MarshalJSON renders the changes as a compact JSON report: an array of
+// objects with the "path" and "kind" of each change, its "old" and "new"
+// values when present, and "old_kind" and "new_kind" for type changes.
+func (c Changes) MarshalJSON() ([]byte, error) {
+	report := make([]changeJSON, len(c))
+	for i, ch := range c {
+		j := changeJSON{Path: ch.Path, Kind: ch.Kind.String()}
+		if ch.Kind == ChangeTypeChanged {
+			j.OldKind, j.NewKind = kindName(ch.Old), kindName(ch.New)
+		}
+		var err error
+		if ch.Old != nil {
+			if j.Old, err = ch.Old.MarshalJSON(); err != nil {
+				return nil, err
+			}
+		}
+		if ch.New != nil {
+			if j.New, err = ch.New.MarshalJSON(); err != nil {
+				return nil, err
+			}
+		}
+		report[i] = j
+	}
+	return json.Marshal(report)
+}
@@ -0,0 +1,113 @@
+package structpb
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []string // path and kind of each change
+	}{
+		{`{"a":1}`, `{"a":1}`, nil},
+		{`{"a":1}`, `{"a":2}`, []string{"/a modified"}},
+		{`{"a":1}`, `{"b":1}`, []string{"/a removed", "/b added"}},
+		{`{"a":1}`, `{"a":1.0}`, []string{"/a type-changed"}},
+		{`{"a":{"b":[1,2,3]}}`, `{"a":{"b":[1,4]}}`, []string{"/a/b/1 modified", "/a/b/2 removed"}},
+		{`[1]`, `[1,{"x":null}]`, []string{"/1 added"}},
+		{`[1,2,3]`, `[0,1,2,3]`, []string{"/0 added"}},
+		{`[1,2,3,4]`, `[1,3,4,5]`, []string{"/1 removed", "/3 added"}},
+		{`[{"a":1},2]`, `[{"a":2},2]`, []string{"/0/a modified"}},
+		{`{"a/b":{"~":1}}`, `{"a/b":{"~":2}}`, []string{"/a~1b/~0 modified"}},
+		{`{"a":[]}`, `{"a":{}}`, []string{"/a type-changed"}},
+		{`1`, `"1"`, []string{" type-changed"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, c := range Diff(mustParse(t, tt.a), mustParse(t, tt.b)) {
+			got = append(got, fmt.Sprintf("%s %s", c.Path, c.Kind))
+		}
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("Diff(%s, %s) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+
+		// CreatePatch describes the same changes at the same paths
+		var ops []string
+		for _, op := range CreatePatch(mustParse(t, tt.a), mustParse(t, tt.b)) {
+			ops = append(ops, op.Path)
+		}
+		var paths []string
+		for _, c := range Diff(mustParse(t, tt.a), mustParse(t, tt.b)) {
+			paths = append(paths, c.Path)
+		}
+		if strings.Join(ops, ", ") != strings.Join(paths, ", ") {
+			t.Errorf("CreatePatch(%s, %s) paths = %q, Diff paths = %q", tt.a, tt.b, ops, paths)
+		}
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	c := Diff(mustParse(t, `{"a":1,"b":2}`), mustParse(t, `{"a":3,"c":4}`))
+	if len(c) != 3 {
+		t.Fatalf("Diff = %v, want 3 changes", c)
+	}
+	for _, tt := range []struct {
+		change   Change
+		old, new string
+	}{
+		{c[0], `1`, `3`},
+		{c[1], `2`, ``},
+		{c[2], ``, `4`},
+	} {
+		var old, new string
+		if tt.change.Old != nil {
+			old = toJSON(t, tt.change.Old)
+		}
+		if tt.change.New != nil {
+			new = toJSON(t, tt.change.New)
+		}
+		if old != tt.old || new != tt.new {
+			t.Errorf("change at %s = %q -> %q, want %q -> %q", tt.change.Path, old, new, tt.old, tt.new)
+		}
+	}
+}
+
+func TestChangesReports(t *testing.T) {
+	c := Diff(mustParse(t, `{"a":1,"b":"x","d":1}`), mustParse(t, `{"a":2,"d":1.5,"e":null}`))
+
+	wantText := `@@ /a modified @@
+-1
++2
+@@ /b removed @@
+-"x"
+@@ /d type-changed: IntValue -> FloatValue @@
+-1
++1.5
+@@ /e added @@
++null
+`
+	if got := c.Text(); got != wantText {
+		t.Errorf("Text =\n%s\nwant\n%s", got, wantText)
+	}
+	if got := c.String(); got != wantText {
+		t.Errorf("String =\n%s\nwant the Text", got)
+	}
+
+	wantJSON := `[{"path":"/a","kind":"modified","old":1,"new":2},` +
+		`{"path":"/b","kind":"removed","old":"x"},` +
+		`{"path":"/d","kind":"type-changed","old_kind":"IntValue","new_kind":"FloatValue","old":1,"new":1.5},` +
+		`{"path":"/e","kind":"added","new":null}]`
+	b, err := c.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != wantJSON {
+		t.Errorf("MarshalJSON =\n%s\nwant\n%s", b, wantJSON)
+	}
+
+	if got := Diff(NewIntValue(1), NewIntValue(1)).Text(); got != "" {
+		t.Errorf("Text of no changes = %q", got)
+	}
+}
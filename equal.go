@@ -0,0 +1,404 @@
+package structpb
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An EqualOption relaxes the comparison made by Equal.
+type EqualOption int
+
+const (
+	// NumericEquivalence compares IntValue and FloatValue by their numeric
+	// value, so IntValue(1) equals FloatValue(1).
+	NumericEquivalence EqualOption = iota + 1
+	// NaNEqual makes a NaN FloatValue equal to any other NaN.
+	NaNEqual
+	// NilEqualsEmpty makes a nil Dict or List equal to an empty one.
+	NilEqualsEmpty
+)
+
+type equalConfig struct {
+	numeric  bool
+	nanEqual bool
+	nilEmpty bool
+}
+
+// Equal reports whether a and b are equal.
+//
+// By default the comparison is strict: values must have the same kind,
+// so IntValue(1) and FloatValue(1) differ, NaN is not equal to itself,
+// and a nil Dict or List differs from an empty one. Dict key order is
+// never significant. A nil *Value is equal to a NullValue.
+func Equal(a, b *Value, opts ...EqualOption) bool {
+	var c equalConfig
+	for _, o := range opts {
+		switch o {
+		case NumericEquivalence:
+			c.numeric = true
+		case NaNEqual:
+			c.nanEqual = true
+		case NilEqualsEmpty:
+			c.nilEmpty = true
+		}
+	}
+	return c.equal(a, b)
+}
+
+// valuesEqual is the equality consistent with Compare, used wherever
+// values are compared by their content rather than their representation.
+func valuesEqual(a, b *Value) bool {
+	return equalConfig{nanEqual: true, nilEmpty: true}.equal(a, b)
+}
+
+func (c equalConfig) equal(a, b *Value) bool {
+	if isNullValue(a) || isNullValue(b) {
+		return isNullValue(a) && isNullValue(b)
+	}
+
+	switch av := a.GetKind().(type) {
+	case *Value_BoolValue:
+		bv, ok := b.GetKind().(*Value_BoolValue)
+		return ok && av.BoolValue == bv.BoolValue
+	case *Value_StringValue:
+		bv, ok := b.GetKind().(*Value_StringValue)
+		return ok && av.StringValue == bv.StringValue
+	case *Value_BytesValue:
+		bv, ok := b.GetKind().(*Value_BytesValue)
+		return ok && bytes.Equal(av.BytesValue, bv.BytesValue)
+	case *Value_TimestampValue:
+		bv, ok := b.GetKind().(*Value_TimestampValue)
+		return ok && compareTimes(av.TimestampValue, bv.TimestampValue) == 0
+	case *Value_DurationValue:
+		bv, ok := b.GetKind().(*Value_DurationValue)
+		return ok && compareTimes(av.DurationValue, bv.DurationValue) == 0
+	case *Value_IntValue:
+		switch bv := b.GetKind().(type) {
+		case *Value_IntValue:
+			return av.IntValue == bv.IntValue
+		case *Value_FloatValue:
+			return c.numeric && compareIntFloat(av.IntValue, bv.FloatValue) == 0
+		case *Value_DecimalValue:
+			return c.numeric && compareNumbers(a, b) == 0
+		}
+		return false
+	case *Value_FloatValue:
+		switch bv := b.GetKind().(type) {
+		case *Value_FloatValue:
+			if math.IsNaN(av.FloatValue) || math.IsNaN(bv.FloatValue) {
+				return c.nanEqual && math.IsNaN(av.FloatValue) && math.IsNaN(bv.FloatValue)
+			}
+			return av.FloatValue == bv.FloatValue
+		case *Value_IntValue:
+			return c.numeric && compareIntFloat(bv.IntValue, av.FloatValue) == 0
+		case *Value_DecimalValue:
+			return c.numeric && compareNumbers(a, b) == 0
+		}
+		return false
+	case *Value_DecimalValue:
+		switch b.GetKind().(type) {
+		case *Value_DecimalValue:
+			return compareNumbers(a, b) == 0
+		case *Value_IntValue, *Value_FloatValue:
+			return c.numeric && compareNumbers(a, b) == 0
+		}
+		return false
+	case *Value_DictValue:
+		bv, ok := b.GetKind().(*Value_DictValue)
+		if !ok {
+			return false
+		}
+		if !c.nilEmpty && (av.DictValue == nil) != (bv.DictValue == nil) {
+			return false
+		}
+		af, bf := av.DictValue.GetFields(), bv.DictValue.GetFields()
+		if len(af) != len(bf) {
+			return false
+		}
+		for k, v := range af {
+			w, ok := bf[k]
+			if !ok || !c.equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case *Value_ListValue:
+		bv, ok := b.GetKind().(*Value_ListValue)
+		if !ok {
+			return false
+		}
+		if !c.nilEmpty && (av.ListValue == nil) != (bv.ListValue == nil) {
+			return false
+		}
+		as, bs := av.ListValue.GetValues(), bv.ListValue.GetValues()
+		if len(as) != len(bs) {
+			return false
+		}
+		for i := range as {
+			if !c.equal(as[i], bs[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// kindRank orders the kinds for Compare.
+func kindRank(x *Value) int {
+	switch x.GetKind().(type) {
+	case *Value_BoolValue:
+		return 1
+	case *Value_IntValue, *Value_FloatValue, *Value_DecimalValue:
+		return 2
+	case *Value_StringValue:
+		return 3
+	case *Value_BytesValue:
+		return 4
+	case *Value_TimestampValue:
+		return 5
+	case *Value_DurationValue:
+		return 6
+	case *Value_ListValue:
+		return 7
+	case *Value_DictValue:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// Compare returns an integer comparing a and b, defining a total order
+// over all Values: -1 if a < b, 0 if a == b, and +1 if a > b.
+//
+// Values are first ordered by kind: null < bool < number < string < bytes
+// < timestamp < duration < List < Dict. Booleans order false before true.
+// Numbers are ordered by their numeric value regardless of their kind,
+// with NaN before all other numbers, and IntValue before a FloatValue
+// before a DecimalValue of the same value. Strings and bytes are ordered
+// byte-wise, timestamps and durations chronologically. Lists are ordered
+// lexicographically by their elements. Dicts are ordered lexicographically
+// by their sorted keys, each key followed by its value.
+//
+// Compare returns 0 exactly when Equal(a, b, NaNEqual, NilEqualsEmpty) is true.
+func Compare(a, b *Value) int {
+	ra, rb := kindRank(a), kindRank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+
+	if ra == 2 {
+		if c := compareNumbers(a, b); c != 0 {
+			return c
+		}
+		return compareInts(int64(numberKindRank(a)), int64(numberKindRank(b)))
+	}
+
+	switch av := a.GetKind().(type) {
+	case *Value_BoolValue:
+		bv := b.GetKind().(*Value_BoolValue)
+		switch {
+		case av.BoolValue == bv.BoolValue:
+			return 0
+		case !av.BoolValue:
+			return -1
+		default:
+			return 1
+		}
+	case *Value_StringValue:
+		return strings.Compare(av.StringValue, b.GetStringValue())
+	case *Value_BytesValue:
+		return bytes.Compare(av.BytesValue, b.GetBytesValue())
+	case *Value_TimestampValue:
+		return compareTimes(av.TimestampValue, b.GetTimestampValue())
+	case *Value_DurationValue:
+		return compareTimes(av.DurationValue, b.GetDurationValue())
+	case *Value_ListValue:
+		as, bs := av.ListValue.GetValues(), b.GetListValue().GetValues()
+		for i := 0; i < len(as) && i < len(bs); i++ {
+			if c := Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(int64(len(as)), int64(len(bs)))
+	case *Value_DictValue:
+		ad, bd := av.DictValue, b.GetDictValue()
+		ak, bk := ad.sortedKeys(), bd.sortedKeys()
+		for i := 0; i < len(ak) && i < len(bk); i++ {
+			if c := strings.Compare(ak[i], bk[i]); c != 0 {
+				return c
+			}
+			if c := Compare(ad.Fields[ak[i]], bd.Fields[bk[i]]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(int64(len(ak)), int64(len(bk)))
+	}
+	return 0
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// numberKindRank orders the number kinds of the same value.
+func numberKindRank(x *Value) int {
+	switch x.GetKind().(type) {
+	case *Value_IntValue:
+		return 0
+	case *Value_FloatValue:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// compareNumbers compares two numbers of any kind by their exact value,
+// ordering NaN before all other numbers.
+func compareNumbers(a, b *Value) int {
+	if av, ok := a.GetKind().(*Value_IntValue); ok {
+		switch bv := b.GetKind().(type) {
+		case *Value_IntValue:
+			return compareInts(av.IntValue, bv.IntValue)
+		case *Value_FloatValue:
+			return compareIntFloat(av.IntValue, bv.FloatValue)
+		}
+	}
+	if av, ok := a.GetKind().(*Value_FloatValue); ok {
+		switch bv := b.GetKind().(type) {
+		case *Value_FloatValue:
+			return compareFloats(av.FloatValue, bv.FloatValue)
+		case *Value_IntValue:
+			return -compareIntFloat(bv.IntValue, av.FloatValue)
+		}
+	}
+
+	ra, rb := numberRat(a), numberRat(b)
+	switch {
+	case ra != nil && rb != nil:
+		return ra.Cmp(rb)
+	case ra != nil:
+		// b is NaN or infinite, any finite number sorts like 0
+		return compareFloats(0, numberFloat(b))
+	case rb != nil:
+		return compareFloats(numberFloat(a), 0)
+	default:
+		return compareFloats(numberFloat(a), numberFloat(b))
+	}
+}
+
+// numberFloat returns the nearest float64 to a number of any kind,
+// NaN for an invalid DecimalValue.
+func numberFloat(x *Value) float64 {
+	switch v := x.GetKind().(type) {
+	case *Value_IntValue:
+		return float64(v.IntValue)
+	case *Value_FloatValue:
+		return v.FloatValue
+	case *Value_DecimalValue:
+		f, err := strconv.ParseFloat(v.DecimalValue, 64)
+		if err != nil && !math.IsInf(f, 0) {
+			return math.NaN()
+		}
+		return f
+	}
+	return math.NaN()
+}
+
+// compareTimes compares two Timestamps or Durations.
+func compareTimes(a, b interface {
+	GetSeconds() int64
+	GetNanos() int32
+}) int {
+	if c := compareInts(a.GetSeconds(), b.GetSeconds()); c != 0 {
+		return c
+	}
+	return compareInts(int64(a.GetNanos()), int64(b.GetNanos()))
+}
+
+// compareFloats orders NaN before all other numbers.
+func compareFloats(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareIntFloat compares an integer and a float exactly,
+// without converting the integer to a float.
+func compareIntFloat(i int64, f float64) int {
+	switch {
+	case math.IsNaN(f):
+		return 1
+	case f >= math.MaxInt64: // 2^63, the first float above MaxInt64
+		return -1
+	case f < math.MinInt64:
+		return 1
+	}
+
+	t := math.Trunc(f)
+	if c := compareInts(i, int64(t)); c != 0 {
+		return c
+	}
+	switch {
+	case f > t:
+		return -1
+	case f < t:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Sort sorts the elements of x in place, in the order defined by Compare.
+func (x *List) Sort() {
+	if x == nil {
+		return
+	}
+	sort.SliceStable(x.Values, func(i, j int) bool {
+		return Compare(x.Values[i], x.Values[j]) < 0
+	})
+}
+
+// SortUnique sorts the elements of x in place, in the order defined by
+// Compare, and removes duplicates, so x can be used as a sorted set.
+func (x *List) SortUnique() {
+	if x == nil || len(x.Values) == 0 {
+		return
+	}
+	x.Sort()
+	values := x.Values[:1]
+	for _, v := range x.Values[1:] {
+		if Compare(values[len(values)-1], v) != 0 {
+			values = append(values, v)
+		}
+	}
+	for i := len(values); i < len(x.Values); i++ {
+		x.Values[i] = nil
+	}
+	x.Values = values
+}
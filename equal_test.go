@@ -0,0 +1,133 @@
+package structpb
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEqual(t *testing.T) {
+	nan := NewFloatValue(math.NaN())
+	nilDict := &Value{Kind: &Value_DictValue{}}
+	nilList := &Value{Kind: &Value_ListValue{}}
+	dec := func(s string) *Value {
+		v, err := NewDecimalValue(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name string
+		a, b *Value
+		opts []EqualOption
+		want bool
+	}{
+		{"same int", NewIntValue(1), NewIntValue(1), nil, true},
+		{"int and float", NewIntValue(1), NewFloatValue(1), nil, false},
+		{"int and float numeric", NewIntValue(1), NewFloatValue(1), []EqualOption{NumericEquivalence}, true},
+		{"int and decimal numeric", NewIntValue(1), dec("1.0"), []EqualOption{NumericEquivalence}, true},
+		{"different numbers numeric", NewIntValue(1), NewFloatValue(1.5), []EqualOption{NumericEquivalence}, false},
+		{"big int numeric", NewIntValue(1<<53 + 1), NewFloatValue(1 << 53), []EqualOption{NumericEquivalence}, false},
+		{"nan", nan, nan, nil, false},
+		{"nan equal", nan, NewFloatValue(math.NaN()), []EqualOption{NaNEqual}, true},
+		{"nil dict", nilDict, NewStructValue(NewEmptyDict()), nil, false},
+		{"nil dict equal", nilDict, NewStructValue(NewEmptyDict()), []EqualOption{NilEqualsEmpty}, true},
+		{"nil list equal", nilList, NewListValue(NewEmptyList()), []EqualOption{NilEqualsEmpty}, true},
+		{"nil value is null", nil, NewNullValue(), nil, true},
+		{"key order", mustParse(t, `{"a":1,"b":2}`), mustParse(t, `{"b":2,"a":1}`), nil, true},
+		{"nested", mustParse(t, `{"a":[1,{"b":null}]}`), mustParse(t, `{"a":[1,{"b":null}]}`), nil, true},
+		{"nested differs", mustParse(t, `{"a":[1,{"b":null}]}`), mustParse(t, `{"a":[1,{"b":false}]}`), nil, false},
+		{"missing key", mustParse(t, `{"a":1}`), mustParse(t, `{"a":1,"b":1}`), nil, false},
+		{"bytes", NewBytesValue([]byte("x")), NewBytesValue([]byte("x")), nil, true},
+		{"bytes and string", NewBytesValue([]byte("x")), NewStringValue("x"), nil, false},
+		{"timestamp", NewTimestampValue(time.Unix(1, 0)), NewTimestampValue(time.Unix(1, 0)), nil, true},
+	}
+	for _, tt := range tests {
+		if got := Equal(tt.a, tt.b, tt.opts...); got != tt.want {
+			t.Errorf("%s: Equal = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := Equal(tt.b, tt.a, tt.opts...); got != tt.want {
+			t.Errorf("%s: Equal reversed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	dec := func(s string) *Value {
+		v, err := NewDecimalValue(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	// in ascending order
+	ordered := []*Value{
+		NewNullValue(),
+		NewBoolValue(false),
+		NewBoolValue(true),
+		NewFloatValue(math.NaN()),
+		NewFloatValue(math.Inf(-1)),
+		NewIntValue(-1),
+		NewIntValue(1),
+		NewFloatValue(1),
+		dec("1"),
+		NewFloatValue(1.5),
+		dec("18446744073709551616"),
+		NewFloatValue(math.Inf(1)),
+		NewStringValue(""),
+		NewStringValue("a"),
+		NewStringValue("b"),
+		NewBytesValue([]byte("a")),
+		NewTimestampValue(time.Unix(0, 0)),
+		NewTimestampValue(time.Unix(1, 0)),
+		NewDurationValue(-time.Second),
+		NewDurationValue(time.Second),
+		mustParse(t, `[]`),
+		mustParse(t, `[1]`),
+		mustParse(t, `[1,2]`),
+		mustParse(t, `[2]`),
+		mustParse(t, `{}`),
+		mustParse(t, `{"a":1}`),
+		mustParse(t, `{"a":2}`),
+		mustParse(t, `{"b":0}`),
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = +1
+			}
+			if got := Compare(a, b); got != want {
+				t.Errorf("Compare(%v, %v) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestCompareMatchesEqual(t *testing.T) {
+	values := []*Value{
+		nil,
+		NewNullValue(),
+		NewFloatValue(math.NaN()),
+		NewIntValue(0),
+		NewFloatValue(0),
+		&Value{Kind: &Value_DictValue{}},
+		NewStructValue(NewEmptyDict()),
+		&Value{Kind: &Value_ListValue{}},
+		NewListValue(NewEmptyList()),
+		mustParse(t, `{"a":[1,2]}`),
+		mustParse(t, `{"a":[1,2.0]}`),
+	}
+	for _, a := range values {
+		for _, b := range values {
+			eq := Equal(a, b, NaNEqual, NilEqualsEmpty)
+			if c := Compare(a, b); (c == 0) != eq {
+				t.Errorf("Compare(%v, %v) = %d but Equal = %v", a, b, c, eq)
+			}
+		}
+	}
+}
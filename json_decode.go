@@ -0,0 +1,522 @@
+package structpb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// jsonDecoder parses JSON text into Values in a single pass.
+type jsonDecoder struct {
+	opts UnmarshalOptions
+	data []byte
+	pos  int
+
+	// offset is the position of data in the input, for error messages.
+	offset int64
+
+	depth int // current nesting of objects and arrays
+	nodes int // number of values decoded so far
+
+	// path holds the tokens of the value being decoded, only if they are
+	// needed to report duplicate keys, see trackPath.
+	path []string
+}
+
+// checkDuplicates reports whether duplicate keys must be detected.
+func (d *jsonDecoder) checkDuplicates() bool {
+	return d.opts.DuplicateKeys != DuplicateKeyLastWins || d.opts.OnDuplicateKey != nil
+}
+
+// trackPath reports whether d.path is maintained.
+func (d *jsonDecoder) trackPath() bool {
+	return d.opts.DuplicateKeys == DuplicateKeyReject || d.opts.OnDuplicateKey != nil
+}
+
+// duplicateKey handles a duplicate key according to the DuplicateKeys
+// policy, it reports whether the new value replaces the previous one.
+func (d *jsonDecoder) duplicateKey(key string) (bool, error) {
+	path := ""
+	for _, token := range d.path {
+		path = appendPointer(path, token)
+	}
+	err := &DuplicateKeyError{Path: appendPointer(path, key), Key: key}
+	if d.opts.DuplicateKeys == DuplicateKeyReject {
+		return false, err
+	}
+	if d.opts.OnDuplicateKey != nil {
+		d.opts.OnDuplicateKey(err)
+	}
+	return d.opts.DuplicateKeys == DuplicateKeyLastWins, nil
+}
+
+// withPath records the token of the value in which a descendant reported
+// a *LimitError, so its path is built only when the error occurs, see
+// finishPath.
+func withPath(err error, token string) error {
+	if le, ok := err.(*LimitError); ok {
+		le.tokens = append(le.tokens, token)
+	}
+	return err
+}
+
+// finishPath sets the path of a *LimitError from the recorded tokens.
+func finishPath(err error) error {
+	if le, ok := err.(*LimitError); ok {
+		for i := len(le.tokens) - 1; i >= 0; i-- {
+			le.Path = appendPointer(le.Path, le.tokens[i])
+		}
+		le.tokens = nil
+	}
+	return err
+}
+
+func (d *jsonDecoder) checkString(s string) error {
+	if max := d.opts.MaxStringLength; max > 0 && len(s) > max {
+		return &LimitError{Limit: "MaxStringLength", Max: int64(max)}
+	}
+	return nil
+}
+
+// enter accounts for a nested object or array, leave must be called once
+// it is decoded.
+func (d *jsonDecoder) enter() error {
+	d.depth++
+	if max := d.opts.maxDepth(); max > 0 && d.depth > max {
+		return &LimitError{Limit: "MaxDepth", Max: int64(max)}
+	}
+	return nil
+}
+
+func (d *jsonDecoder) leave() { d.depth-- }
+
+func (d *jsonDecoder) syntaxError(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid json at offset %d: %s", d.offset+int64(d.pos), fmt.Sprintf(format, args...))
+}
+
+func (d *jsonDecoder) unexpected(context string) error {
+	if d.pos >= len(d.data) {
+		return fmt.Errorf("unexpected end of json input")
+	}
+	return d.syntaxError("invalid character %q %s", d.data[d.pos], context)
+}
+
+func (d *jsonDecoder) skipSpace() {
+	for d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
+
+// end checks that only whitespace is left.
+func (d *jsonDecoder) end() error {
+	d.skipSpace()
+	if d.pos < len(d.data) {
+		return d.unexpected("after top-level value")
+	}
+	return nil
+}
+
+// peek returns the next non-space byte, or 0 at the end of input.
+func (d *jsonDecoder) peek() byte {
+	d.skipSpace()
+	if d.pos < len(d.data) {
+		return d.data[d.pos]
+	}
+	return 0
+}
+
+func (d *jsonDecoder) consumeLiteral(lit string) bool {
+	if len(d.data)-d.pos >= len(lit) && string(d.data[d.pos:d.pos+len(lit)]) == lit {
+		d.pos += len(lit)
+		return true
+	}
+	return false
+}
+
+func (d *jsonDecoder) decodeValue(x *Value) error {
+	d.nodes++
+	if max := d.opts.MaxNodes; max > 0 && d.nodes > max {
+		return &LimitError{Limit: "MaxNodes", Max: int64(max)}
+	}
+	switch c := d.peek(); c {
+	case '"':
+		s, err := d.readString()
+		if err != nil {
+			return err
+		}
+		if err := d.checkString(s); err != nil {
+			return err
+		}
+		if d.opts.NonFinite == NonFiniteQuoted {
+			if f, ok := parseNonFinite(s); ok {
+				x.Kind = &Value_FloatValue{FloatValue: f}
+				return nil
+			}
+		}
+		x.Kind = &Value_StringValue{StringValue: s}
+		return nil
+	case '{':
+		dict := &Dict{Fields: map[string]*Value{}, Ordered: d.opts.PreserveKeyOrder}
+		if err := d.decodeDict(dict); err != nil {
+			return err
+		}
+		x.Kind = &Value_DictValue{DictValue: dict}
+		return nil
+	case '[':
+		list := &List{}
+		if err := d.decodeList(list); err != nil {
+			return err
+		}
+		x.Kind = &Value_ListValue{ListValue: list}
+		return nil
+	case 'n':
+		if d.consumeLiteral("null") {
+			x.Kind = &Value_NullValue{NullValue: NullValue_NULL_VALUE}
+			return nil
+		}
+	case 't':
+		if d.consumeLiteral("true") {
+			x.Kind = &Value_BoolValue{BoolValue: true}
+			return nil
+		}
+	case 'f':
+		if d.consumeLiteral("false") {
+			x.Kind = &Value_BoolValue{BoolValue: false}
+			return nil
+		}
+	case 'N', 'I', '-':
+		if d.opts.NonFinite == NonFiniteToken {
+			for _, lit := range []string{"NaN", "Infinity", "-Infinity"} {
+				if d.consumeLiteral(lit) {
+					f, _ := parseNonFinite(lit)
+					x.Kind = &Value_FloatValue{FloatValue: f}
+					return nil
+				}
+			}
+		}
+		if c == '-' {
+			return d.decodeNumber(x)
+		}
+	default:
+		if c >= '0' && c <= '9' {
+			return d.decodeNumber(x)
+		}
+	}
+	return d.unexpected("looking for beginning of value")
+}
+
+// parseNonFinite parses the names used for non-finite floats.
+func parseNonFinite(s string) (float64, bool) {
+	switch s {
+	case "NaN":
+		return math.NaN(), true
+	case "Infinity":
+		return math.Inf(+1), true
+	case "-Infinity":
+		return math.Inf(-1), true
+	}
+	return 0, false
+}
+
+func (d *jsonDecoder) decodeDict(x *Dict) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	d.pos++ // '{'
+	if d.peek() == '}' {
+		d.pos++
+		return nil
+	}
+	// Keys are looked up in x.Fields, unless x is the Dict being
+	// unmarshalled into and already has fields.
+	var seen map[string]struct{}
+	if d.checkDuplicates() && len(x.Fields) > 0 {
+		seen = map[string]struct{}{}
+	}
+	for members := 1; ; members++ {
+		if max := d.opts.MaxDictKeys; max > 0 && members > max {
+			return &LimitError{Limit: "MaxDictKeys", Max: int64(max)}
+		}
+		if d.peek() != '"' {
+			return d.unexpected("looking for beginning of object key string")
+		}
+		key, err := d.readString()
+		if err != nil {
+			return err
+		}
+		if err := d.checkString(key); err != nil {
+			return err
+		}
+		if d.peek() != ':' {
+			return d.unexpected("after object key")
+		}
+		d.pos++
+
+		v := &Value{}
+		if d.trackPath() {
+			d.path = append(d.path, key)
+		}
+		if err := d.decodeValue(v); err != nil {
+			return withPath(err, key)
+		}
+		if d.trackPath() {
+			d.path = d.path[:len(d.path)-1]
+		}
+
+		set := true
+		if d.checkDuplicates() {
+			dup := false
+			if seen != nil {
+				_, dup = seen[key]
+				seen[key] = struct{}{}
+			} else {
+				_, dup = x.Fields[key]
+			}
+			if dup {
+				if set, err = d.duplicateKey(key); err != nil {
+					return err
+				}
+			}
+		}
+		if set {
+			x.Set(key, v)
+		}
+
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case '}':
+			d.pos++
+			return nil
+		default:
+			return d.unexpected("after object key:value pair")
+		}
+	}
+}
+
+func (d *jsonDecoder) decodeList(x *List) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	d.pos++ // '['
+	x.Values = []*Value{}
+	if d.peek() == ']' {
+		d.pos++
+		return nil
+	}
+	for {
+		if max := d.opts.MaxListLength; max > 0 && len(x.Values) >= max {
+			return &LimitError{Limit: "MaxListLength", Max: int64(max)}
+		}
+		v := &Value{}
+		if d.trackPath() {
+			d.path = append(d.path, strconv.Itoa(len(x.Values)))
+		}
+		if err := d.decodeValue(v); err != nil {
+			return withPath(err, strconv.Itoa(len(x.Values)))
+		}
+		if d.trackPath() {
+			d.path = d.path[:len(d.path)-1]
+		}
+		x.Values = append(x.Values, v)
+
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case ']':
+			d.pos++
+			return nil
+		default:
+			return d.unexpected("after array element")
+		}
+	}
+}
+
+// readNumber consumes a number, checking its syntax.
+func (d *jsonDecoder) readNumber() ([]byte, error) {
+	start := d.pos
+	digits := func() bool {
+		n := d.pos
+		for d.pos < len(d.data) && d.data[d.pos] >= '0' && d.data[d.pos] <= '9' {
+			d.pos++
+		}
+		return d.pos > n
+	}
+
+	if d.pos < len(d.data) && d.data[d.pos] == '-' {
+		d.pos++
+	}
+	if d.pos < len(d.data) && d.data[d.pos] == '0' {
+		d.pos++
+	} else if !digits() {
+		return nil, d.unexpected("in numeric literal")
+	}
+	if d.pos < len(d.data) && d.data[d.pos] == '.' {
+		d.pos++
+		if !digits() {
+			return nil, d.unexpected("after decimal point in numeric literal")
+		}
+	}
+	if d.pos < len(d.data) && (d.data[d.pos] == 'e' || d.data[d.pos] == 'E') {
+		d.pos++
+		if d.pos < len(d.data) && (d.data[d.pos] == '+' || d.data[d.pos] == '-') {
+			d.pos++
+		}
+		if !digits() {
+			return nil, d.unexpected("in exponent of numeric literal")
+		}
+	}
+	return d.data[start:d.pos], nil
+}
+
+func (d *jsonDecoder) decodeNumber(x *Value) error {
+	p, err := d.readNumber()
+	if err != nil {
+		return err
+	}
+
+	if isIntegralNumber(p) {
+		if intValue, err := strconv.ParseInt(string(p), 10, 64); err == nil {
+			x.Kind = &Value_IntValue{IntValue: intValue}
+			return nil
+		}
+		v, err := d.opts.BigNumbers.bigNumber(string(p))
+		if err != nil {
+			return err
+		}
+		x.Kind = v.Kind
+		return nil
+	}
+	floatValue, err := strconv.ParseFloat(string(p), 64)
+	if err != nil {
+		return fmt.Errorf("invalid json data %s", p)
+	}
+	x.Kind = &Value_FloatValue{FloatValue: floatValue}
+	return nil
+}
+
+// readString consumes a quoted string and returns its unescaped content.
+// Like encoding/json, invalid UTF-8 and unpaired surrogates are replaced
+// by U+FFFD.
+func (d *jsonDecoder) readString() (string, error) {
+	d.pos++ // '"'
+	start := d.pos
+
+	// fast path: no escapes nor invalid bytes
+	for d.pos < len(d.data) {
+		c := d.data[d.pos]
+		if c == '"' {
+			s := string(d.data[start:d.pos])
+			d.pos++
+			return s, nil
+		}
+		if c == '\\' || c < 0x20 || c >= utf8.RuneSelf {
+			break
+		}
+		d.pos++
+	}
+
+	b := make([]byte, d.pos-start, d.pos-start+16)
+	copy(b, d.data[start:d.pos])
+	for d.pos < len(d.data) {
+		c := d.data[d.pos]
+		switch {
+		case c == '"':
+			d.pos++
+			return string(b), nil
+		case c < 0x20:
+			return "", d.syntaxError("invalid character %q in string literal", c)
+		case c == '\\':
+			d.pos++
+			if d.pos >= len(d.data) {
+				return "", d.unexpected("in string escape code")
+			}
+			switch e := d.data[d.pos]; e {
+			case '"', '\\', '/':
+				b = append(b, e)
+			case 'b':
+				b = append(b, '\b')
+			case 'f':
+				b = append(b, '\f')
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'u':
+				r, ok := d.readHex4(d.pos + 1)
+				if !ok {
+					return "", d.syntaxError("invalid \\u escape in string literal")
+				}
+				d.pos += 4
+				if utf16.IsSurrogate(r) {
+					r2, ok := rune(-1), false
+					if d.pos+2 < len(d.data) && d.data[d.pos+1] == '\\' && d.data[d.pos+2] == 'u' {
+						r2, ok = d.readHex4(d.pos + 3)
+					}
+					if dec := utf16.DecodeRune(r, r2); ok && dec != utf8.RuneError {
+						r = dec
+						d.pos += 6
+					} else {
+						r = utf8.RuneError
+					}
+				}
+				b = appendRune(b, r)
+			default:
+				return "", d.syntaxError("invalid character %q in string escape code", e)
+			}
+			d.pos++
+		case c < utf8.RuneSelf:
+			b = append(b, c)
+			d.pos++
+		default:
+			r, size := utf8.DecodeRune(d.data[d.pos:])
+			if r == utf8.RuneError && size == 1 {
+				b = appendRune(b, utf8.RuneError)
+			} else {
+				b = append(b, d.data[d.pos:d.pos+size]...)
+			}
+			d.pos += size
+		}
+	}
+	return "", fmt.Errorf("unexpected end of json input")
+}
+
+func (d *jsonDecoder) readHex4(at int) (rune, bool) {
+	if at+4 > len(d.data) {
+		return 0, false
+	}
+	var r rune
+	for _, c := range d.data[at : at+4] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r*16 + rune(c)
+	}
+	return r, true
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return append(b, buf[:n]...)
+}
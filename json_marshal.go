@@ -1,41 +1,445 @@
 package structpb
 
 import (
-	"bytes"
-	"encoding/json"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
 	"github.com/golang/protobuf/jsonpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-func marshalJSON(m *jsonpb.Marshaler, data interface{}) ([]byte, error) {
-	result, err := json.Marshal(data)
-	if err != nil {
+// MarshalOptions is a configurable JSON format marshaler.
+type MarshalOptions struct {
+	// Indent, if not empty, puts every Dict field and List element on its
+	// own line, indented by one copy of Indent per nesting level.
+	Indent string
+
+	// PreserveNumberKind writes every FloatValue with a fractional part or
+	// an exponent, such as 2.0 instead of 2, so it is decoded back as a
+	// FloatValue rather than an IntValue.
+	PreserveNumberKind bool
+
+	// NonFinite selects how NaN and infinite floats are written,
+	// see NonFinitePolicy.
+	NonFinite NonFinitePolicy
+
+	// Prefix, if Indent is not empty, begins every line after the first
+	// one, like the prefix of json.MarshalIndent.
+	Prefix string
+
+	// SortKeys writes the keys of ordered Dicts in sorted order too, see
+	// Dict.Keys. The keys of other Dicts are always sorted.
+	SortKeys bool
+
+	// DisableHTMLEscape writes the characters <, > and & as is in strings.
+	// By default they are escaped as \u003c, \u003e and \u0026, like
+	// encoding/json does, so the output can be embedded in HTML.
+	DisableHTMLEscape bool
+
+	// FloatFormat, if not zero, is the format of finite FloatValues, one of
+	// 'e', 'E', 'f' or 'g' as in strconv.FormatFloat, with the smallest
+	// number of digits that reads back exactly. Other formats are reported
+	// as an error. By default floats are formatted like in ECMAScript, as
+	// encoding/json does.
+	FloatFormat byte
+
+	// Int64AsString writes IntValues as strings, such as "9007199254740993",
+	// like the JSON mapping of the protobuf int64 type, so consumers which
+	// read every number as a double, such as JavaScript, do not lose
+	// precision.
+	Int64AsString bool
+}
+
+// NonFinitePolicy defines how NaN, Infinity and -Infinity FloatValues are
+// represented in JSON, which has no syntax for them.
+type NonFinitePolicy int
+
+const (
+	// NonFiniteString writes the strings "NaN", "Infinity" and "-Infinity",
+	// like AsInterface does. They are unmarshalled as StringValue, so the
+	// float kind is lost on a round trip. This is the default.
+	NonFiniteString NonFinitePolicy = iota
+	// NonFiniteError fails to marshal non-finite floats.
+	NonFiniteError
+	// NonFiniteNull writes non-finite floats as null.
+	NonFiniteNull
+	// NonFiniteQuoted writes the same strings as NonFiniteString, and
+	// unmarshals exactly these strings back as FloatValue.
+	NonFiniteQuoted
+	// NonFiniteToken writes and reads the bare tokens NaN, Infinity and
+	// -Infinity, as in JSON5. The output is not valid JSON.
+	NonFiniteToken
+)
+
+// Marshal writes the given *Value, *Dict or *List in JSON format.
+//
+// JSON has no binary nor time types: BytesValue is written as a base64
+// string, TimestampValue as an RFC 3339 string in UTC and DurationValue
+// as a string of seconds such as "1.5s". They are read back as StringValue.
+func (o MarshalOptions) Marshal(m proto.Message) ([]byte, error) {
+	e := jsonEncoder{opts: o}
+	if err := e.encode(m); err != nil {
 		return nil, err
 	}
+	return e.buf, nil
+}
 
-	if m != nil && m.Indent != "" {
-		buf := new(bytes.Buffer)
-		err = json.Indent(buf, result, "", m.Indent)
-		if err != nil {
-			return result, nil
-		} else {
-			return buf.Bytes(), nil
-		}
-	} else {
-		return result, nil
+// An Encoder writes Values in JSON format to an output stream.
+type Encoder struct {
+	opts MarshalOptions
+	w    io.Writer
+}
+
+// NewEncoder returns an Encoder writing to w with the default options.
+func NewEncoder(w io.Writer) *Encoder {
+	return MarshalOptions{}.NewEncoder(w)
+}
+
+// NewEncoder returns an Encoder writing to w with the options o.
+func (o MarshalOptions) NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{opts: o, w: w}
+}
+
+// Encode writes the given *Value, *Dict or *List in JSON format, followed
+// by a newline, see MarshalOptions.Marshal.
+//
+// The tree is walked and written in chunks, so memory use does not grow
+// with its size. The first error returned by the writer is reported, and
+// stops the encoding; the output may then be incomplete, as it is when
+// the value cannot be marshalled.
+func (enc *Encoder) Encode(m proto.Message) error {
+	e := jsonEncoder{opts: enc.opts, w: enc.w}
+	if err := e.encode(m); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, '\n')
+	e.flush()
+	return e.err
+}
+
+// marshalJSON implements MarshalJSONPB. Only the Indent option applies,
+// the other jsonpb options are about message fields and enums. Messages
+// holding Dict, List or Value fields are better marshalled with
+// MarshalProtoJSON, as jsonpb is deprecated.
+func marshalJSON(m *jsonpb.Marshaler, x proto.Message) ([]byte, error) {
+	var o MarshalOptions
+	if m != nil {
+		o.Indent = m.Indent
 	}
+	return o.Marshal(x)
 }
 
 func (x *Dict) MarshalJSONPB(m *jsonpb.Marshaler) ([]byte, error) {
-	return marshalJSON(m, x.AsMap())
+	return marshalJSON(m, x)
 }
 func (x *Dict) MarshalJSON() ([]byte, error) { return x.MarshalJSONPB(nil) }
 
 func (x *List) MarshalJSONPB(m *jsonpb.Marshaler) ([]byte, error) {
-	return marshalJSON(m, x.AsSlice())
+	return marshalJSON(m, x)
 }
 func (x *List) MarshalJSON() ([]byte, error) { return x.MarshalJSONPB(nil) }
 
 func (x *Value) MarshalJSONPB(m *jsonpb.Marshaler) ([]byte, error) {
-	return marshalJSON(m, x.AsInterface())
+	return marshalJSON(m, x)
 }
 func (x *Value) MarshalJSON() ([]byte, error) { return x.MarshalJSONPB(nil) }
+
+// jsonEncoder writes Values in JSON format. With the default options, the
+// output is the same as encoding/json would produce for the result of
+// Value.AsInterface, except that DurationValues are written as strings
+// such as "1.5s" rather than integer nanoseconds, and the keys of ordered
+// Dicts keep their order.
+//
+// If w is not nil, buf is written to it whenever it grows over
+// encoderChunkSize, so it is bounded.
+type jsonEncoder struct {
+	opts  MarshalOptions
+	w     io.Writer
+	buf   []byte
+	depth int
+	err   error
+}
+
+const encoderChunkSize = 32 << 10
+
+func (e *jsonEncoder) encode(m proto.Message) error {
+	switch e.opts.FloatFormat {
+	case 0, 'e', 'E', 'f', 'g':
+	default:
+		// other formats, such as 'b' or 'x', are not JSON numbers
+		return fmt.Errorf("invalid FloatFormat %q", e.opts.FloatFormat)
+	}
+	switch m := m.(type) {
+	case *Value:
+		e.encodeValue(m)
+	case *Dict:
+		e.encodeDict(m)
+	case *List:
+		e.encodeList(m)
+	default:
+		return fmt.Errorf("cannot marshal %T, only *Value, *Dict and *List are supported", m)
+	}
+	return e.err
+}
+
+// flush writes buf to w, recording the first error.
+func (e *jsonEncoder) flush() {
+	if e.err != nil {
+		return
+	}
+	if _, err := e.w.Write(e.buf); err != nil {
+		e.err = err
+	}
+	e.buf = e.buf[:0]
+}
+
+// next is called between elements, it flushes buf if it is too large and
+// reports whether to go on.
+func (e *jsonEncoder) next() bool {
+	if e.w != nil && len(e.buf) >= encoderChunkSize {
+		e.flush()
+	}
+	return e.err == nil
+}
+
+func (e *jsonEncoder) newline() {
+	if e.opts.Indent == "" {
+		return
+	}
+	e.buf = append(e.buf, '\n')
+	e.buf = append(e.buf, e.opts.Prefix...)
+	for i := 0; i < e.depth; i++ {
+		e.buf = append(e.buf, e.opts.Indent...)
+	}
+}
+
+func (e *jsonEncoder) encodeValue(x *Value) {
+	switch v := x.GetKind().(type) {
+	case *Value_BoolValue:
+		e.buf = strconv.AppendBool(e.buf, v.BoolValue)
+	case *Value_IntValue:
+		if e.opts.Int64AsString {
+			e.buf = append(e.buf, '"')
+			e.buf = strconv.AppendInt(e.buf, v.IntValue, 10)
+			e.buf = append(e.buf, '"')
+		} else {
+			e.buf = strconv.AppendInt(e.buf, v.IntValue, 10)
+		}
+	case *Value_FloatValue:
+		e.encodeFloat(v.FloatValue)
+	case *Value_StringValue:
+		e.buf = appendJSONString(e.buf, !e.opts.DisableHTMLEscape, v.StringValue)
+	case *Value_DecimalValue:
+		if !isNumber(v.DecimalValue) && e.err == nil {
+			e.err = fmt.Errorf("invalid DecimalValue %q", v.DecimalValue)
+		}
+		e.buf = append(e.buf, v.DecimalValue...)
+	case *Value_BytesValue:
+		e.buf = appendJSONString(e.buf, !e.opts.DisableHTMLEscape, base64.StdEncoding.EncodeToString(v.BytesValue))
+	case *Value_TimestampValue:
+		if err := v.TimestampValue.CheckValid(); err != nil && e.err == nil {
+			e.err = err
+		}
+		e.buf = appendJSONString(e.buf, !e.opts.DisableHTMLEscape, formatTimestamp(v.TimestampValue))
+	case *Value_DurationValue:
+		if err := v.DurationValue.CheckValid(); err != nil && e.err == nil {
+			e.err = err
+		}
+		e.buf = appendJSONString(e.buf, !e.opts.DisableHTMLEscape, formatDuration(v.DurationValue))
+	case *Value_DictValue:
+		e.encodeDict(v.DictValue)
+	case *Value_ListValue:
+		e.encodeList(v.ListValue)
+	default:
+		e.buf = append(e.buf, "null"...)
+	}
+}
+
+func (e *jsonEncoder) encodeFloat(f float64) {
+	var name string
+	switch {
+	case math.IsNaN(f):
+		name = "NaN"
+	case math.IsInf(f, +1):
+		name = "Infinity"
+	case math.IsInf(f, -1):
+		name = "-Infinity"
+	}
+	switch {
+	case name == "":
+		start := len(e.buf)
+		if e.opts.FloatFormat != 0 {
+			e.buf = strconv.AppendFloat(e.buf, f, e.opts.FloatFormat, -1, 64)
+		} else {
+			e.buf = appendES6Float(e.buf, f)
+		}
+		if e.opts.PreserveNumberKind && isIntegralNumber(e.buf[start:]) {
+			e.buf = append(e.buf, ".0"...)
+		}
+	case e.opts.NonFinite == NonFiniteError:
+		if e.err == nil {
+			e.err = fmt.Errorf("cannot marshal non-finite float %s", name)
+		}
+	case e.opts.NonFinite == NonFiniteNull:
+		e.buf = append(e.buf, "null"...)
+	case e.opts.NonFinite == NonFiniteToken:
+		e.buf = append(e.buf, name...)
+	default:
+		e.buf = append(e.buf, '"')
+		e.buf = append(e.buf, name...)
+		e.buf = append(e.buf, '"')
+	}
+}
+
+// formatTimestamp formats ts in RFC 3339 format in UTC.
+func formatTimestamp(ts *timestamppb.Timestamp) string {
+	return ts.AsTime().Format(time.RFC3339Nano)
+}
+
+// formatDuration formats d like the JSON mapping of google.protobuf.Duration:
+// seconds with 0, 3, 6 or 9 fractional digits, followed by "s".
+func formatDuration(d *durationpb.Duration) string {
+	secs, nanos := d.GetSeconds(), d.GetNanos()
+	sign := ""
+	if secs < 0 || nanos < 0 {
+		sign, secs, nanos = "-", -secs, -nanos
+	}
+	s := sign + strconv.FormatInt(secs, 10)
+	if nanos != 0 {
+		frac := fmt.Sprintf("%09d", nanos)
+		for strings.HasSuffix(frac, "000") {
+			frac = frac[:len(frac)-3]
+		}
+		s += "." + frac
+	}
+	return s + "s"
+}
+
+// isIntegralNumber reports whether a JSON number has neither a fractional
+// part nor an exponent, so it reads as an integer.
+func isIntegralNumber(p []byte) bool {
+	for _, c := range p {
+		if c == '.' || c == 'e' || c == 'E' {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *jsonEncoder) encodeDict(x *Dict) {
+	keys := x.Keys()
+	if e.opts.SortKeys && x.GetOrdered() {
+		keys = x.sortedKeys()
+	}
+	if len(keys) == 0 {
+		e.buf = append(e.buf, "{}"...)
+		return
+	}
+
+	e.buf = append(e.buf, '{')
+	e.depth++
+	for i, k := range keys {
+		if !e.next() {
+			return
+		}
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.newline()
+		e.buf = appendJSONString(e.buf, !e.opts.DisableHTMLEscape, k)
+		e.buf = append(e.buf, ':')
+		if e.opts.Indent != "" {
+			e.buf = append(e.buf, ' ')
+		}
+		e.encodeValue(x.Fields[k])
+	}
+	e.depth--
+	e.newline()
+	e.buf = append(e.buf, '}')
+}
+
+func (e *jsonEncoder) encodeList(x *List) {
+	values := x.GetValues()
+	if len(values) == 0 {
+		e.buf = append(e.buf, "[]"...)
+		return
+	}
+
+	e.buf = append(e.buf, '[')
+	e.depth++
+	for i, v := range values {
+		if !e.next() {
+			return
+		}
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.newline()
+		e.encodeValue(v)
+	}
+	e.depth--
+	e.newline()
+	e.buf = append(e.buf, ']')
+}
+
+// appendJSONString quotes s like encoding/json: HTML special characters,
+// if escapeHTML is true, and U+2028, U+2029 are escaped, and invalid UTF-8
+// is replaced by U+FFFD.
+func appendJSONString(b []byte, escapeHTML bool, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && (!escapeHTML || c != '<' && c != '>' && c != '&') {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
@@ -0,0 +1,264 @@
+package structpb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestMarshalPreserveNumberKind(t *testing.T) {
+	tests := []struct {
+		in         *Value
+		want, kind string
+	}{
+		{NewFloatValue(2), `2.0`, `2`},
+		{NewFloatValue(-2), `-2.0`, `-2`},
+		{NewFloatValue(math.Copysign(0, -1)), `-0.0`, `-0`},
+		{NewFloatValue(1e20), `100000000000000000000.0`, `100000000000000000000`},
+		{NewFloatValue(1e21), `1e+21`, `1e+21`},
+		{NewFloatValue(0.5), `0.5`, `0.5`},
+		{NewIntValue(2), `2`, `2`},
+	}
+	for _, tt := range tests {
+		b, err := MarshalOptions{PreserveNumberKind: true}.Marshal(tt.in)
+		if err != nil || string(b) != tt.want {
+			t.Errorf("Marshal(%v) with PreserveNumberKind = %s, %v, want %s", tt.in, b, err, tt.want)
+		}
+		if got := toJSON(t, tt.in); got != tt.kind {
+			t.Errorf("Marshal(%v) = %s, want %s", tt.in, got, tt.kind)
+		}
+	}
+}
+
+func TestPreserveNumberKindRoundTrip(t *testing.T) {
+	d := NewEmptyDict()
+	d.Set("int", NewIntValue(2))
+	d.Set("float", NewFloatValue(2))
+	d.Set("list", NewListValue(&List{Values: []*Value{NewFloatValue(0), NewIntValue(0), NewFloatValue(1e21)}}))
+
+	b, err := MarshalOptions{PreserveNumberKind: true}.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &Dict{}
+	if err := got.UnmarshalJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(NewStructValue(got), NewStructValue(d)) {
+		t.Errorf("round trip through %s = %v, want %v", b, got, d)
+	}
+
+	// without the option, the kind of integral floats is lost
+	b, err = MarshalOptions{}.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.UnmarshalJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	if kindName(got.Fields["float"]) != "IntValue" {
+		t.Errorf("float decoded from %s as %s", b, kindName(got.Fields["float"]))
+	}
+}
+
+func TestMarshalNonFinite(t *testing.T) {
+	l := &List{Values: []*Value{
+		NewFloatValue(math.NaN()),
+		NewFloatValue(math.Inf(1)),
+		NewFloatValue(math.Inf(-1)),
+		NewFloatValue(1),
+	}}
+	tests := []struct {
+		policy NonFinitePolicy
+		want   string // "" if an error is expected
+	}{
+		{NonFiniteString, `["NaN","Infinity","-Infinity",1]`},
+		{NonFiniteError, ``},
+		{NonFiniteNull, `[null,null,null,1]`},
+		{NonFiniteQuoted, `["NaN","Infinity","-Infinity",1]`},
+		{NonFiniteToken, `[NaN,Infinity,-Infinity,1]`},
+	}
+	for _, tt := range tests {
+		b, err := MarshalOptions{NonFinite: tt.policy}.Marshal(l)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Marshal with policy %d = %s, want an error", tt.policy, b)
+			}
+			continue
+		}
+		if err != nil || string(b) != tt.want {
+			t.Errorf("Marshal with policy %d = %s, %v, want %s", tt.policy, b, err, tt.want)
+		}
+	}
+}
+
+func TestNonFiniteRoundTrip(t *testing.T) {
+	in := &List{Values: []*Value{
+		NewFloatValue(math.NaN()),
+		NewFloatValue(math.Inf(1)),
+		NewFloatValue(math.Inf(-1)),
+	}}
+	for _, policy := range []NonFinitePolicy{NonFiniteQuoted, NonFiniteToken} {
+		b, err := MarshalOptions{NonFinite: policy}.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := &List{}
+		if err := (UnmarshalOptions{NonFinite: policy}).Unmarshal(b, out); err != nil {
+			t.Errorf("Unmarshal(%s) with policy %d: %v", b, policy, err)
+			continue
+		}
+		if !Equal(NewListValue(out), NewListValue(in), NaNEqual) {
+			t.Errorf("round trip with policy %d through %s = %v", policy, b, out)
+		}
+	}
+
+	// by default, the strings stay strings and the tokens are invalid
+	if got := kindName(mustParse(t, `"NaN"`)); got != "StringValue" {
+		t.Errorf(`UnmarshalJSON("NaN") = %s, want StringValue`, got)
+	}
+	if err := (&Value{}).UnmarshalJSON([]byte(`NaN`)); err == nil {
+		t.Error("UnmarshalJSON(NaN) succeeded")
+	}
+	// NonFiniteQuoted only reads the exact names
+	x := &Value{}
+	if err := (UnmarshalOptions{NonFinite: NonFiniteQuoted}).Unmarshal([]byte(`"nan"`), x); err != nil || kindName(x) != "StringValue" {
+		t.Errorf(`Unmarshal("nan") with NonFiniteQuoted = %v, %v, want a StringValue`, x, err)
+	}
+}
+
+func TestMarshalOptions(t *testing.T) {
+	ordered := NewOrderedDict()
+	ordered.Set("z", NewStringValue("<a&b>"))
+	ordered.Set("a", NewListValue(&List{Values: []*Value{NewIntValue(9007199254740993), NewFloatValue(1e-7)}}))
+	tests := []struct {
+		name string
+		opts MarshalOptions
+		want string
+	}{
+		{"default", MarshalOptions{}, `{"z":"\u003ca\u0026b\u003e","a":[9007199254740993,1e-7]}`},
+		{"sort keys", MarshalOptions{SortKeys: true}, `{"a":[9007199254740993,1e-7],"z":"\u003ca\u0026b\u003e"}`},
+		{"no html escape", MarshalOptions{DisableHTMLEscape: true}, `{"z":"<a&b>","a":[9007199254740993,1e-7]}`},
+		{"float f", MarshalOptions{FloatFormat: 'f'}, `{"z":"\u003ca\u0026b\u003e","a":[9007199254740993,0.0000001]}`},
+		{"float E", MarshalOptions{FloatFormat: 'E'}, `{"z":"\u003ca\u0026b\u003e","a":[9007199254740993,1E-07]}`},
+		{"int64 as string", MarshalOptions{Int64AsString: true}, `{"z":"\u003ca\u0026b\u003e","a":["9007199254740993",1e-7]}`},
+		{"indent", MarshalOptions{Indent: "  ", Prefix: "#"}, "{\n#  \"z\": \"\\u003ca\\u0026b\\u003e\",\n#  \"a\": [\n#    9007199254740993,\n#    1e-7\n#  ]\n#}"},
+		{"prefix without indent", MarshalOptions{Prefix: "#"}, `{"z":"\u003ca\u0026b\u003e","a":[9007199254740993,1e-7]}`},
+	}
+	for _, tt := range tests {
+		b, err := tt.opts.Marshal(ordered)
+		if err != nil {
+			t.Errorf("%s: Marshal: %v", tt.name, err)
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("%s: Marshal = %s, want %s", tt.name, b, tt.want)
+		}
+	}
+
+	for _, format := range []byte{'b', 'x', 'G', 'v'} {
+		if _, err := (MarshalOptions{FloatFormat: format}).Marshal(NewFloatValue(1)); err == nil {
+			t.Errorf("Marshal with FloatFormat %q succeeded", format)
+		}
+	}
+}
+
+// countingWriter records the size of each write, and fails the writes
+// past limit bytes if limit is not negative.
+type countingWriter struct {
+	buf    bytes.Buffer
+	writes []int
+	failed int
+	limit  int
+}
+
+var errWriteLimit = errors.New("write limit reached")
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, len(p))
+	if w.limit >= 0 && w.buf.Len()+len(p) > w.limit {
+		w.failed++
+		return 0, errWriteLimit
+	}
+	return w.buf.Write(p)
+}
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := MarshalOptions{Indent: "\t"}.NewEncoder(&buf)
+	for _, s := range []string{`{"b":[1,{}],"a":"<"}`, `[]`, `null`} {
+		if err := enc.Encode(mustParse(t, s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "{\n\t\"a\": \"\\u003c\",\n\t\"b\": [\n\t\t1,\n\t\t{}\n\t]\n}\n[]\nnull\n"
+	if buf.String() != want {
+		t.Errorf("Encode = %q, want %q", buf.String(), want)
+	}
+
+	if err := NewEncoder(&buf).Encode(wrapperspb.Int64(1)); err == nil {
+		t.Error("Encode of an unsupported message succeeded")
+	}
+	if err := (MarshalOptions{NonFinite: NonFiniteError}).NewEncoder(&buf).Encode(NewFloatValue(math.NaN())); err == nil {
+		t.Error("Encode of NaN with NonFiniteError succeeded")
+	}
+}
+
+// largeList returns a List of n Dicts, each holding a string of 100 bytes.
+func largeList(n int) *List {
+	l := &List{Values: make([]*Value, n)}
+	s := strings.Repeat("x", 100)
+	for i := range l.Values {
+		l.Values[i] = NewStructValue(&Dict{Fields: map[string]*Value{"s": NewStringValue(s), "i": NewIntValue(int64(i))}})
+	}
+	return l
+}
+
+func TestEncoderChunks(t *testing.T) {
+	l := largeList(10000)
+	want, err := MarshalOptions{}.Marshal(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &countingWriter{limit: -1}
+	if err := NewEncoder(w).Encode(l); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.buf.String(); got != string(want)+"\n" {
+		t.Errorf("Encode differs from Marshal")
+	}
+	if len(w.writes) < 2 {
+		t.Errorf("Encode wrote %d bytes in %d writes, want several", w.buf.Len(), len(w.writes))
+	}
+	for _, n := range w.writes {
+		if n > 2*encoderChunkSize {
+			t.Errorf("Encode wrote %d bytes at once, want at most about %d", n, encoderChunkSize)
+			break
+		}
+	}
+
+	// a failed write stops the encoding
+	w = &countingWriter{limit: 4 * encoderChunkSize}
+	if err := NewEncoder(w).Encode(l); !errors.Is(err, errWriteLimit) {
+		t.Errorf("Encode to a failing writer = %v, want %v", err, errWriteLimit)
+	}
+	if w.failed != 1 {
+		t.Errorf("Encode went on writing after a failure, %d writes failed", w.failed)
+	}
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	l := largeList(10000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := NewEncoder(io.Discard).Encode(l); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,90 +1,403 @@
 package structpb
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+
 	"github.com/golang/protobuf/jsonpb"
+	"google.golang.org/protobuf/proto"
 )
 
 var _ json.Unmarshaler = (*List)(nil)
 var _ json.Unmarshaler = (*Dict)(nil)
 var _ json.Unmarshaler = (*Value)(nil)
 
+// UnmarshalOptions is a configurable JSON format parser.
+//
+// Numbers written without a fractional part nor an exponent, such as 2,
+// are decoded as IntValue, and all others, such as 2.0 or 1e3, as
+// FloatValue even when they are integral, so FloatValues written by
+// MarshalOptions with PreserveNumberKind keep their kind. This is always
+// the case, so there is no matching unmarshal option.
+type UnmarshalOptions struct {
+	// NonFinite selects which JSON forms are read as non-finite floats,
+	// see NonFinitePolicy. By default none are, and "NaN" stays a string.
+	NonFinite NonFinitePolicy
+
+	// BigNumbers selects how integers outside of the int64 range are
+	// decoded, see BigNumberPolicy. By default they become FloatValue.
+	BigNumbers BigNumberPolicy
+
+	// PreserveKeyOrder decodes JSON objects into ordered Dicts, which keep
+	// the keys in the order of the input, including the Dict unmarshalled
+	// into. Unmarshalling into an ordered Dict always preserves the key
+	// order.
+	PreserveKeyOrder bool
+
+	// DuplicateKeys selects how an object with the same key more than once
+	// is decoded, see DuplicateKeyPolicy. By default the last value wins,
+	// like encoding/json.
+	DuplicateKeys DuplicateKeyPolicy
+
+	// OnDuplicateKey, if set, is called for every duplicate key that does
+	// not fail decoding, to report it as a warning.
+	OnDuplicateKey func(*DuplicateKeyError)
+
+	// The following limits protect against untrusted input, exceeding one
+	// of them is reported as a *LimitError. Zero means no limit, except
+	// for MaxDepth.
+
+	// MaxDepth limits the nesting of objects and arrays. If zero, 10000 is
+	// used, like encoding/json; if negative, the depth is not limited.
+	MaxDepth int
+
+	// MaxBytes limits the size of the input, or of each value read by a
+	// Decoder.
+	MaxBytes int64
+
+	// MaxStringLength limits the length in bytes of strings and keys,
+	// once unescaped.
+	MaxStringLength int
+
+	// MaxDictKeys limits the number of members of each object, counting
+	// those with a duplicate key, even if DuplicateKeys discards them.
+	MaxDictKeys int
+
+	// MaxListLength limits the number of elements of each array.
+	MaxListLength int
+
+	// MaxNodes limits the total number of values.
+	MaxNodes int
+}
+
+// DuplicateKeyPolicy defines how a JSON object with the same key more than
+// once is decoded. Such objects are valid JSON but ambiguous: parsers that
+// disagree on which value is used can be made to check one value and act
+// on another.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeyLastWins keeps the last value of the key, like
+	// encoding/json. This is the default.
+	DuplicateKeyLastWins DuplicateKeyPolicy = iota
+	// DuplicateKeyFirstWins keeps the first value of the key, the later
+	// ones are still checked but discarded.
+	DuplicateKeyFirstWins
+	// DuplicateKeyReject fails to decode with a *DuplicateKeyError.
+	DuplicateKeyReject
+)
+
+// A DuplicateKeyError reports a key that appears more than once in a JSON
+// object.
+type DuplicateKeyError struct {
+	// Path is the JSON Pointer of the duplicated member, its last token
+	// is Key.
+	Path string
+	Key  string
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("json has duplicate key %q at %s", e.Key, e.Path)
+}
+
+// A LimitError reports JSON input exceeding one of the limits of
+// UnmarshalOptions.
+type LimitError struct {
+	// Limit is the name of the exceeded UnmarshalOptions field, such as
+	// "MaxDepth".
+	Limit string
+	// Max is the value of the limit.
+	Max int64
+	// Path is the JSON Pointer of the value exceeding it, "" is the root.
+	Path string
+
+	tokens []string // reversed tokens of Path, while it is being built
+}
+
+func (e *LimitError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "root"
+	}
+	return fmt.Sprintf("json exceeds %s of %d at %s", e.Limit, e.Max, path)
+}
+
+// maxDepth returns the effective MaxDepth, or 0 if it is not limited.
+func (o UnmarshalOptions) maxDepth() int {
+	switch {
+	case o.MaxDepth == 0:
+		return 10000
+	case o.MaxDepth < 0:
+		return 0
+	default:
+		return o.MaxDepth
+	}
+}
+
+// Unmarshal reads the given *Value, *Dict or *List from JSON format. On
+// error, m is left unchanged.
+func (o UnmarshalOptions) Unmarshal(p []byte, m proto.Message) error {
+	d := jsonDecoder{opts: o, data: p}
+	return d.unmarshal(m)
+}
+
+// A Decoder reads successive Values in JSON format from an input stream.
+type Decoder struct {
+	opts   UnmarshalOptions
+	r      io.Reader
+	buf    []byte
+	offset int64 // position of buf in the input
+	err    error // sticky read error
+}
+
+// NewDecoder returns a Decoder reading from r with the default options.
+func NewDecoder(r io.Reader) *Decoder {
+	return UnmarshalOptions{}.NewDecoder(r)
+}
+
+// NewDecoder returns a Decoder reading from r with the options o.
+func (o UnmarshalOptions) NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{opts: o, r: r}
+}
+
+// Decode reads the next JSON value from the input into the given *Value,
+// *Dict or *List, see UnmarshalOptions.Unmarshal. Values may be separated
+// by whitespace, as in JSON Lines. Decode returns io.EOF when the input is
+// exhausted.
+//
+// The Decoder buffers one value at a time, and parses it in a single pass
+// once its end is found.
+func (dec *Decoder) Decode(m proto.Message) error {
+	if err := dec.skipSpace(); err != nil {
+		return err
+	}
+	n, err := dec.scanValue()
+	if err != nil {
+		return err
+	}
+	d := jsonDecoder{opts: dec.opts, data: dec.buf[:n], offset: dec.offset}
+	dec.buf = dec.buf[n:]
+	dec.offset += int64(n)
+	return d.unmarshal(m)
+}
+
+// refill reads more input into buf.
+func (dec *Decoder) refill() error {
+	if dec.err != nil {
+		return dec.err
+	}
+	if cap(dec.buf)-len(dec.buf) < 512 {
+		buf := make([]byte, len(dec.buf), 2*cap(dec.buf)+4096)
+		copy(buf, dec.buf)
+		dec.buf = buf
+	}
+	n, err := dec.r.Read(dec.buf[len(dec.buf):cap(dec.buf)])
+	dec.buf = dec.buf[:len(dec.buf)+n]
+	if err != nil {
+		dec.err = err
+		if n > 0 {
+			return nil
+		}
+	}
+	return err
+}
+
+// skipSpace discards the whitespace at the start of buf.
+func (dec *Decoder) skipSpace() error {
+	for {
+		for i, c := range dec.buf {
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				dec.buf = dec.buf[i:]
+				dec.offset += int64(i)
+				return nil
+			}
+		}
+		dec.offset += int64(len(dec.buf))
+		dec.buf = dec.buf[:0]
+		if err := dec.refill(); err != nil {
+			return err
+		}
+	}
+}
+
+// scanValue reads input until buf holds the value it starts with, and
+// returns its length. It only tracks nesting and strings; the syntax is
+// checked by jsonDecoder.
+func (dec *Decoder) scanValue() (int, error) {
+	depth := 0
+	inString, escape := false, false
+	for i := 0; ; i++ {
+		if max := dec.opts.MaxBytes; max > 0 && int64(i) > max {
+			return 0, &LimitError{Limit: "MaxBytes", Max: max}
+		}
+		for i >= len(dec.buf) {
+			if err := dec.refill(); err != nil {
+				if err == io.EOF {
+					if depth == 0 && !inString {
+						return i, nil // a number or literal ends the input
+					}
+					err = io.ErrUnexpectedEOF
+				}
+				return 0, err
+			}
+		}
+		switch c := dec.buf[i]; {
+		case depth == 0 && i > 0 && !inString && (c == '"' || c == '{' || c == '['):
+			return i, nil // a number or literal is followed by another value
+		case inString:
+			switch {
+			case escape:
+				escape = false
+			case c == '\\':
+				escape = true
+			case c == '"':
+				inString = false
+				if depth == 0 {
+					return i + 1, nil
+				}
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth <= 0 {
+				return i + 1, nil
+			}
+		case depth == 0 && (c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' || c == ':'):
+			if i == 0 {
+				return 1, nil
+			}
+			return i, nil
+		}
+	}
+}
+
+func (d *jsonDecoder) unmarshal(m proto.Message) error {
+	if max := d.opts.MaxBytes; max > 0 && int64(len(d.data)) > max {
+		return &LimitError{Limit: "MaxBytes", Max: max}
+	}
+	var err error
+	switch m := m.(type) {
+	case *Value:
+		err = d.unmarshalValue(m)
+	case *Dict:
+		err = d.unmarshalDict(m)
+	case *List:
+		err = d.unmarshalList(m)
+	default:
+		return fmt.Errorf("cannot unmarshal %T, only *Value, *Dict and *List are supported", m)
+	}
+	return finishPath(err)
+}
+
 func (x *List) UnmarshalJSON(p []byte) error {
-	return json.Unmarshal(p, &x.Values)
+	return UnmarshalOptions{}.Unmarshal(p, x)
 }
 func (x *List) UnmarshalJSONPB(_ *jsonpb.Unmarshaler, p []byte) error {
 	return x.UnmarshalJSON(p)
 }
 
 func (x *Dict) UnmarshalJSON(p []byte) error {
-	if x.Fields == nil {
-		x.Fields = make(map[string]*Value)
-	}
-	return json.Unmarshal(p, &x.Fields)
+	return UnmarshalOptions{}.Unmarshal(p, x)
 }
 func (x *Dict) UnmarshalJSONPB(_ *jsonpb.Unmarshaler, p []byte) error {
 	return x.UnmarshalJSON(p)
 }
 
 func (x *Value) UnmarshalJSON(p []byte) error {
-	p = bytes.TrimSpace(p)
-	if len(p) == 0 {
-		return fmt.Errorf("unexpected end")
-	}
+	return UnmarshalOptions{}.Unmarshal(p, x)
+}
+func (x *Value) UnmarshalJSONPB(_ *jsonpb.Unmarshaler, p []byte) error {
+	return x.UnmarshalJSON(p)
+}
 
-	switch p[0] {
-	case '"':
-		var s string
-		err := json.Unmarshal(p, &s)
-		if err != nil {
+// unmarshalList, unmarshalDict and unmarshalValue decode into a new
+// message and only store the result into x on success, so that x is left
+// unchanged by an error.
+func (d *jsonDecoder) unmarshalList(x *List) error {
+	list := &List{}
+	switch d.peek() {
+	case '[':
+		if err := d.decodeList(list); err != nil {
 			return err
 		}
-		x.Kind = &Value_StringValue{StringValue: s}
-		return nil
+	case 'n':
+		if !d.consumeLiteral("null") {
+			return d.unexpected("in literal null")
+		}
+	default:
+		return fmt.Errorf("cannot unmarshal %s into a List", describeJSON(d))
+	}
+	if err := d.end(); err != nil {
+		return err
+	}
+	x.Values = list.Values
+	return nil
+}
+
+func (d *jsonDecoder) unmarshalDict(x *Dict) error {
+	// the members are added to a copy of x
+	dict := &Dict{Fields: make(map[string]*Value, len(x.Fields)), Ordered: x.Ordered}
+	for k, v := range x.Fields {
+		dict.Fields[k] = v
+	}
+	switch {
+	case x.Ordered:
+		d.opts.PreserveKeyOrder = true
+		dict.KeyOrder = append([]string(nil), x.KeyOrder...)
+	case d.opts.PreserveKeyOrder:
+		// existing fields come first, in the order of Keys
+		dict.KeyOrder = x.sortedKeys()
+		dict.Ordered = true
+	}
+	switch d.peek() {
 	case '{':
-		var s Dict
-		err := s.UnmarshalJSON(p)
-		if err != nil {
+		if err := d.decodeDict(dict); err != nil {
 			return err
 		}
-		x.Kind = &Value_DictValue{DictValue: &s}
-		return nil
-	case '[':
-		var l List
-		err := l.UnmarshalJSON(p)
-		if err != nil {
-			return err
+	case 'n':
+		if !d.consumeLiteral("null") {
+			return d.unexpected("in literal null")
 		}
-		x.Kind = &Value_ListValue{ListValue: &l}
-		return nil
+	default:
+		return fmt.Errorf("cannot unmarshal %s into a Dict", describeJSON(d))
 	}
-
-	switch {
-	case bytes.Equal(p, []byte("null")):
-		x.Kind = &Value_NullValue{NullValue: NullValue_NULL_VALUE}
-		return nil
-	case bytes.Equal(p, []byte("true")):
-		x.Kind = &Value_BoolValue{BoolValue: true}
-		return nil
-	case bytes.Equal(p, []byte("false")):
-		x.Kind = &Value_BoolValue{BoolValue: false}
-		return nil
+	if err := d.end(); err != nil {
+		return err
 	}
+	x.Fields, x.KeyOrder, x.Ordered = dict.Fields, dict.KeyOrder, dict.Ordered
+	return nil
+}
 
-	num := json.Number(p)
-	if intValue, err := num.Int64(); err == nil {
-		x.Kind = &Value_IntValue{IntValue: intValue}
-		return nil
+func (d *jsonDecoder) unmarshalValue(x *Value) error {
+	v := &Value{}
+	if err := d.decodeValue(v); err != nil {
+		return err
 	}
-	if floatValue, err := num.Float64(); err == nil {
-		x.Kind = &Value_FloatValue{FloatValue: floatValue}
-		return nil
+	if err := d.end(); err != nil {
+		return err
 	}
-
-	return fmt.Errorf("invalid json data %s", p)
+	x.Kind = v.Kind
+	return nil
 }
-func (x *Value) UnmarshalJSONPB(_ *jsonpb.Unmarshaler, p []byte) error {
-	return x.UnmarshalJSON(p)
+
+// describeJSON names the kind of the JSON value at the decoder position.
+func describeJSON(d *jsonDecoder) string {
+	switch c := d.peek(); {
+	case c == 0:
+		return "empty input"
+	case c == '"':
+		return "string"
+	case c == '[':
+		return "array"
+	case c == '{':
+		return "object"
+	case c == 't' || c == 'f':
+		return "bool"
+	default:
+		return "number"
+	}
 }
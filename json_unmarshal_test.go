@@ -0,0 +1,368 @@
+package structpb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalNumberKinds(t *testing.T) {
+	tests := []struct {
+		in, kind string
+	}{
+		{`2`, "IntValue"},
+		{`-0`, "IntValue"},
+		{`-9223372036854775808`, "IntValue"},
+		{`2.0`, "FloatValue"},
+		{`-0.0`, "FloatValue"},
+		{`1e3`, "FloatValue"},
+		{`1E+2`, "FloatValue"},
+		{`9223372036854775808`, "FloatValue"},
+	}
+	for _, tt := range tests {
+		if got := kindName(mustParse(t, tt.in)); got != tt.kind {
+			t.Errorf("UnmarshalJSON(%s) = %s, want %s", tt.in, got, tt.kind)
+		}
+	}
+}
+
+func TestUnmarshalPreserveNumberKind(t *testing.T) {
+	in := NewListValue(&List{Values: []*Value{
+		NewFloatValue(2), NewFloatValue(-2), NewFloatValue(math.Copysign(0, -1)),
+		NewFloatValue(1e20), NewFloatValue(1e21), NewFloatValue(0.5),
+		NewIntValue(2), NewIntValue(0), NewIntValue(math.MinInt64),
+		NewStructValue(&Dict{Fields: map[string]*Value{"f": NewFloatValue(3), "i": NewIntValue(3)}}),
+	}})
+	b, err := MarshalOptions{PreserveNumberKind: true}.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []UnmarshalOptions{{}, {PreserveKeyOrder: true}, {BigNumbers: BigNumberDecimal}} {
+		out := &Value{}
+		if err := o.Unmarshal(b, out); err != nil {
+			t.Fatal(err)
+		}
+		if !Equal(out, in) {
+			t.Errorf("Unmarshal(%s) with %+v = %v, want the kinds of %v", b, o, out, in)
+		}
+	}
+}
+
+func TestUnmarshalStrings(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`"a\"\\\/\b\f\n\r\t"`, "a\"\\/\b\f\n\r\t"},
+		{`"é中"`, "é中"},
+		{`"😀"`, "😀"},
+		{`"\ud83d"`, "�"},
+		{`"\ude00x"`, "�x"},
+		{"\"\xff\"", "�"},
+		{`"<&>"`, "<&>"},
+	}
+	for _, tt := range tests {
+		if got := mustParse(t, tt.in).GetStringValue(); got != tt.want {
+			t.Errorf("UnmarshalJSON(%s) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalSyntax(t *testing.T) {
+	tests := []string{
+		``, ` `, `nul`, `True`, `01`, `-`, `1.`, `.5`, `1e`, `+1`, `0x10`,
+		`"abc`, `"\x"`, `"\u12"`, "\"a\tb\"", `[1,]`, `[1 2]`, `{"a"}`, `{"a":1,}`,
+		`{a:1}`, `{"a":1]`, `[`, `{`, `1 2`, `[] x`, `NaN`,
+		` {"a" : [ 1 , true , null , "s" ] } `, `-0.5e-3`, `[[[]]]`, `{"":{}}`,
+	}
+	for _, in := range tests {
+		x := &Value{}
+		err := x.UnmarshalJSON([]byte(in))
+		if valid := json.Valid([]byte(in)); (err == nil) != valid {
+			t.Errorf("UnmarshalJSON(%q) error = %v, want valid %v like encoding/json", in, err, valid)
+		}
+	}
+}
+
+func TestUnmarshalInto(t *testing.T) {
+	d := &Dict{}
+	if err := d.UnmarshalJSON([]byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.UnmarshalJSON([]byte(`{"b":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, d); got != `{"a":1,"b":2}` {
+		t.Errorf("Dict unmarshalled twice = %s, want the members of both", got)
+	}
+	if err := d.UnmarshalJSON([]byte(`[]`)); err == nil {
+		t.Error("UnmarshalJSON of an array into a Dict succeeded")
+	}
+
+	l := &List{}
+	if err := l.UnmarshalJSON([]byte(`null`)); err != nil || l.Values != nil {
+		t.Errorf("UnmarshalJSON(null) into a List = %v, %v", l, err)
+	}
+	if err := l.UnmarshalJSON([]byte(`{}`)); err == nil {
+		t.Error("UnmarshalJSON of an object into a List succeeded")
+	}
+
+	// a failed unmarshal leaves the receiver unchanged
+	for _, ordered := range []bool{false, true} {
+		d := &Dict{Ordered: ordered}
+		d.Set("keep", NewIntValue(1))
+		for _, in := range []string{`{"a":1,"keep":2,"b":`, `{"a":1} x`} {
+			if err := (UnmarshalOptions{PreserveKeyOrder: true}).Unmarshal([]byte(in), d); err == nil {
+				t.Errorf("Unmarshal(%s) into a Dict succeeded", in)
+			}
+			if got := toJSON(t, d); got != `{"keep":1}` || d.Ordered != ordered || len(d.KeyOrder) != len(d.Fields) && ordered {
+				t.Errorf("Dict after a failed Unmarshal(%s) = %s, ordered %v %q, want it unchanged", in, got, d.Ordered, d.KeyOrder)
+			}
+		}
+	}
+	l = &List{Values: []*Value{NewIntValue(9)}}
+	if err := l.UnmarshalJSON([]byte(`[1,2,`)); err == nil || toJSON(t, l) != `[9]` {
+		t.Errorf("List after a failed UnmarshalJSON = %s, %v, want it unchanged", toJSON(t, l), err)
+	}
+	v := NewIntValue(9)
+	if err := v.UnmarshalJSON([]byte(`1 2`)); err == nil || toJSON(t, v) != `9` {
+		t.Errorf("Value after a failed UnmarshalJSON = %s, %v, want it unchanged", toJSON(t, v), err)
+	}
+}
+
+// oneByteReader reads one byte at a time.
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (r oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return r.r.Read(p[:1])
+}
+
+func TestDecoder(t *testing.T) {
+	const in = " {\"a\":[1,\"]}\"]}\n[2]\t\"s\" 3 true\nnull{}\n"
+	want := []string{`{"a":[1,"]}"]}`, `[2]`, `"s"`, `3`, `true`, `null`, `{}`}
+	for _, r := range []io.Reader{strings.NewReader(in), oneByteReader{strings.NewReader(in)}} {
+		dec := NewDecoder(r)
+		var got []string
+		for {
+			x := &Value{}
+			err := dec.Decode(x)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Decode after %v: %v", got, err)
+			}
+			got = append(got, toJSON(t, x))
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("Decode = %v, want %v", got, want)
+		}
+	}
+
+	dec := NewDecoder(strings.NewReader(`{"a":1} [1,}`))
+	if err := dec.Decode(&Dict{}); err != nil {
+		t.Fatal(err)
+	}
+	err := dec.Decode(&List{})
+	if err == nil || !strings.Contains(err.Error(), "offset 11") {
+		t.Errorf("Decode of invalid JSON = %v, want an error at offset 11", err)
+	}
+
+	dec = NewDecoder(strings.NewReader(`{"a":[1`))
+	if err := dec.Decode(&Value{}); err != io.ErrUnexpectedEOF {
+		t.Errorf("Decode of truncated JSON = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+// deepJSON returns n nested arrays around an object.
+func deepJSON(n int) []byte {
+	return []byte(strings.Repeat(`[`, n) + `{"a":1}` + strings.Repeat(`]`, n))
+}
+
+// wideJSON returns an object with n members of various kinds.
+func wideJSON(n int) []byte {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `"key%d":{"s":"value \"%d\"","n":%d,"f":%d.5,"l":[true,null]}`, i, i, i, i)
+	}
+	b.WriteByte('}')
+	return []byte(b.String())
+}
+
+func benchmarkUnmarshal(b *testing.B, p []byte) {
+	b.SetBytes(int64(len(p)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := (&Value{}).UnmarshalJSON(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalDeep(b *testing.B) { benchmarkUnmarshal(b, deepJSON(1000)) }
+func BenchmarkUnmarshalWide(b *testing.B) { benchmarkUnmarshal(b, wideJSON(10000)) }
+
+func BenchmarkDecoder(b *testing.B) {
+	p := bytes.Repeat(append(wideJSON(10), '\n'), 1000)
+	b.SetBytes(int64(len(p)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dec := NewDecoder(bytes.NewReader(p))
+		for {
+			err := dec.Decode(&Value{})
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestUnmarshalLimits(t *testing.T) {
+	tests := []struct {
+		opts  UnmarshalOptions
+		in    string
+		limit string
+		path  string
+	}{
+		{UnmarshalOptions{MaxDepth: 2}, `{"a":[{}]}`, "MaxDepth", "/a/0"},
+		{UnmarshalOptions{MaxBytes: 5}, `[1,2,3]`, "MaxBytes", ""},
+		{UnmarshalOptions{MaxStringLength: 3}, `{"a":["abcd"]}`, "MaxStringLength", "/a/0"},
+		{UnmarshalOptions{MaxStringLength: 3}, `{"a":{"abcd":1}}`, "MaxStringLength", "/a"},
+		{UnmarshalOptions{MaxStringLength: 3}, `"éé"`, "MaxStringLength", ""},
+		{UnmarshalOptions{MaxDictKeys: 2}, `[{"a":1,"b":2,"c":3}]`, "MaxDictKeys", "/0"},
+		{UnmarshalOptions{MaxDictKeys: 1, DuplicateKeys: DuplicateKeyFirstWins}, `{"a":1,"a":2}`, "MaxDictKeys", ""},
+		{UnmarshalOptions{MaxListLength: 2}, `{"x/y":[1,2,3]}`, "MaxListLength", "/x~1y"},
+		{UnmarshalOptions{MaxNodes: 4}, `[[1,2],[3]]`, "MaxNodes", "/1"},
+	}
+	for _, tt := range tests {
+		err := tt.opts.Unmarshal([]byte(tt.in), &Value{})
+		var le *LimitError
+		if !errors.As(err, &le) {
+			t.Errorf("Unmarshal(%s) with %s = %v, want a *LimitError", tt.in, tt.limit, err)
+			continue
+		}
+		if le.Limit != tt.limit || le.Path != tt.path {
+			t.Errorf("Unmarshal(%s) = %s at %q, want %s at %q", tt.in, le.Limit, le.Path, tt.limit, tt.path)
+		}
+	}
+
+	// the limits are inclusive
+	within := UnmarshalOptions{MaxDepth: 3, MaxBytes: 20, MaxStringLength: 2, MaxDictKeys: 2, MaxListLength: 2, MaxNodes: 6}
+	if err := within.Unmarshal([]byte(`{"a":[{"bb":"cc"}],"b":1}`), &Value{}); err == nil {
+		t.Error("Unmarshal of 25 bytes with MaxBytes 20 succeeded")
+	}
+	within.MaxBytes = 25
+	if err := within.Unmarshal([]byte(`{"a":[{"bb":"cc"}],"b":1}`), &Value{}); err != nil {
+		t.Errorf("Unmarshal within the limits: %v", err)
+	}
+}
+
+func TestUnmarshalDefaultMaxDepth(t *testing.T) {
+	var le *LimitError
+	if err := (&Value{}).UnmarshalJSON(deepJSON(10000)); !errors.As(err, &le) || le.Limit != "MaxDepth" {
+		t.Errorf("UnmarshalJSON of 10001 nested values = %v, want MaxDepth to be exceeded", err)
+	}
+	if err := (&Value{}).UnmarshalJSON(deepJSON(9999)); err != nil {
+		t.Errorf("UnmarshalJSON of 10000 nested values: %v", err)
+	}
+	if err := (UnmarshalOptions{MaxDepth: -1}).Unmarshal(deepJSON(20000), &Value{}); err != nil {
+		t.Errorf("Unmarshal of 20001 nested values without MaxDepth: %v", err)
+	}
+}
+
+func TestDecoderMaxBytes(t *testing.T) {
+	dec := UnmarshalOptions{MaxBytes: 8}.NewDecoder(strings.NewReader(`[1,2,3] "a very long string" 1`))
+	if err := dec.Decode(&Value{}); err != nil {
+		t.Fatal(err)
+	}
+	var le *LimitError
+	if err := dec.Decode(&Value{}); !errors.As(err, &le) || le.Limit != "MaxBytes" {
+		t.Errorf("Decode of a value over MaxBytes = %v, want a *LimitError", err)
+	}
+
+	// the Decoder does not buffer more than the limit
+	r := strings.NewReader(`[` + strings.Repeat(`1,`, 1<<20))
+	dec = UnmarshalOptions{MaxBytes: 1 << 10}.NewDecoder(r)
+	if err := dec.Decode(&Value{}); !errors.As(err, &le) {
+		t.Errorf("Decode of an endless value = %v, want a *LimitError", err)
+	}
+	if r.Len() < 1<<20 {
+		t.Errorf("Decode read %d bytes with MaxBytes %d", 2<<20+1-r.Len(), 1<<10)
+	}
+}
+
+func TestUnmarshalDuplicateKeys(t *testing.T) {
+	const in = `{"a":1,"b":{"c":[{"d":1,"d":2}]},"a":3}`
+	tests := []struct {
+		policy DuplicateKeyPolicy
+		want   string
+	}{
+		{DuplicateKeyLastWins, `{"a":3,"b":{"c":[{"d":2}]}}`},
+		{DuplicateKeyFirstWins, `{"a":1,"b":{"c":[{"d":1}]}}`},
+	}
+	for _, tt := range tests {
+		var warnings []string
+		o := UnmarshalOptions{DuplicateKeys: tt.policy, OnDuplicateKey: func(e *DuplicateKeyError) {
+			warnings = append(warnings, e.Path)
+		}}
+		d := &Dict{}
+		if err := o.Unmarshal([]byte(in), d); err != nil {
+			t.Errorf("Unmarshal with policy %d: %v", tt.policy, err)
+			continue
+		}
+		if got := toJSON(t, d); got != tt.want {
+			t.Errorf("Unmarshal with policy %d = %s, want %s", tt.policy, got, tt.want)
+		}
+		if got := strings.Join(warnings, " "); got != "/b/c/0/d /a" {
+			t.Errorf("Unmarshal with policy %d warned about %q", tt.policy, got)
+		}
+	}
+
+	err := UnmarshalOptions{DuplicateKeys: DuplicateKeyReject}.Unmarshal([]byte(in), &Dict{})
+	var de *DuplicateKeyError
+	if !errors.As(err, &de) || de.Key != "d" || de.Path != "/b/c/0/d" {
+		t.Errorf("Unmarshal with DuplicateKeyReject = %v, want a *DuplicateKeyError for d at /b/c/0/d", err)
+	}
+
+	// the keys already in the Dict unmarshalled into are not duplicates
+	d := &Dict{Fields: map[string]*Value{"a": NewIntValue(0)}}
+	if err := (UnmarshalOptions{DuplicateKeys: DuplicateKeyReject}).Unmarshal([]byte(`{"a":1}`), d); err != nil {
+		t.Errorf("Unmarshal of a key already in the Dict: %v", err)
+	}
+	if err := (UnmarshalOptions{DuplicateKeys: DuplicateKeyReject}).Unmarshal([]byte(`{"b":1,"b":2}`), d); !errors.As(err, &de) {
+		t.Errorf("Unmarshal of a duplicate key into a filled Dict = %v, want a *DuplicateKeyError", err)
+	}
+
+	// keys are compared once unescaped
+	err = UnmarshalOptions{DuplicateKeys: DuplicateKeyReject}.Unmarshal([]byte(`{"a/b":1,"a\/b":2}`), &Value{})
+	if !errors.As(err, &de) || de.Path != "/a~1b" {
+		t.Errorf("Unmarshal of escaped duplicate keys = %v, want a *DuplicateKeyError at /a~1b", err)
+	}
+}
+
+func TestDecoderDuplicateKeys(t *testing.T) {
+	dec := UnmarshalOptions{DuplicateKeys: DuplicateKeyReject}.NewDecoder(strings.NewReader(`{"a":1} {"a":1,"a":2}`))
+	if err := dec.Decode(&Value{}); err != nil {
+		t.Fatal(err)
+	}
+	var de *DuplicateKeyError
+	if err := dec.Decode(&Value{}); !errors.As(err, &de) || de.Path != "/a" {
+		t.Errorf("Decode with DuplicateKeyReject = %v, want a *DuplicateKeyError at /a", err)
+	}
+}
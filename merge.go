@@ -0,0 +1,252 @@
+package structpb
+
+import (
+	"strconv"
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to target and returns
+// the result: when patch is a Dict it is merged recursively into target,
+// where null fields delete the corresponding target fields; any other
+// patch replaces target entirely.
+//
+// Neither target nor patch is modified, and the result shares no values
+// with them.
+func MergePatch(target, patch *Value) *Value {
+	p, ok := patch.GetKind().(*Value_DictValue)
+	if !ok {
+		return cloneValue(patch)
+	}
+
+	t := target.GetDictValue()
+	x := &Dict{Fields: make(map[string]*Value, len(t.GetFields())+len(p.DictValue.GetFields()))}
+	if _, ok := target.GetKind().(*Value_DictValue); ok {
+		x.Ordered = t.GetOrdered()
+	} else {
+		x.Ordered = p.DictValue.GetOrdered()
+		t = nil
+	}
+
+	for _, k := range t.Keys() {
+		v, patched := p.DictValue.GetFields()[k]
+		switch {
+		case !patched:
+			x.Set(k, cloneValue(t.Fields[k]))
+		case !isNullValue(v):
+			x.Set(k, MergePatch(t.Fields[k], v))
+		}
+	}
+	for _, k := range p.DictValue.Keys() {
+		v := p.DictValue.GetFields()[k]
+		if _, ok := t.GetFields()[k]; ok || isNullValue(v) {
+			continue
+		}
+		x.Set(k, MergePatch(nil, v))
+	}
+
+	return NewStructValue(x)
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to x and returns the
+// result, see the package level MergePatch.
+func (x *Dict) MergePatch(patch *Dict) *Dict {
+	return MergePatch(NewStructValue(x), NewStructValue(patch)).GetDictValue()
+}
+
+// ListMergeStrategy defines how DeepMerge combines two Lists.
+type ListMergeStrategy int
+
+const (
+	// ListReplace replaces the destination List with the source List.
+	ListReplace ListMergeStrategy = iota
+	// ListAppend appends the source elements to the destination List.
+	ListAppend
+	// ListMergeByKey merges Dict elements which have the same value for
+	// the MergeOptions.MergeKey field, like Kubernetes strategic merge.
+	// Other source elements are appended.
+	ListMergeByKey
+)
+
+// NullMergeStrategy defines how DeepMerge handles null source values.
+type NullMergeStrategy int
+
+const (
+	// NullOverwrite stores the null value, like any other value.
+	NullOverwrite NullMergeStrategy = iota
+	// NullDelete removes the destination field, like JSON Merge Patch.
+	NullDelete
+	// NullIgnore keeps the destination value.
+	NullIgnore
+)
+
+// MergeOptions is a configurable deep merge of Values.
+type MergeOptions struct {
+	// Lists is the strategy used when both values are Lists.
+	Lists ListMergeStrategy
+	// MergeKey is the Dict field identifying List elements for ListMergeByKey.
+	MergeKey string
+	// Nulls is the strategy used when the source value is null.
+	Nulls NullMergeStrategy
+
+	// Conflict, if set, is called when two different values cannot be merged,
+	// that is when they are not both Dicts or both Lists. path is the JSON
+	// Pointer of the values. The returned value is used as the result, and
+	// a returned error aborts the merge.
+	// If not set, the source value wins.
+	Conflict func(path string, dst, src *Value) (*Value, error)
+}
+
+// DeepMerge merges src into dst with the default MergeOptions and returns
+// the result, see MergeOptions.DeepMerge.
+func DeepMerge(dst, src *Value) *Value {
+	v, _ := MergeOptions{}.DeepMerge(dst, src)
+	return v
+}
+
+// DeepMerge merges src into dst and returns the result: Dicts are merged
+// field by field recursively, Lists are merged with the List strategy, and
+// any other source value replaces the destination value.
+//
+// Neither dst nor src is modified, and the result shares no values with them.
+func (o MergeOptions) DeepMerge(dst, src *Value) (*Value, error) {
+	return o.merge("", dst, src)
+}
+
+// DeepMergeDict merges src into x and returns the result,
+// see MergeOptions.DeepMerge.
+func (o MergeOptions) DeepMergeDict(x, src *Dict) (*Dict, error) {
+	v, err := o.merge("", NewStructValue(x), NewStructValue(src))
+	if err != nil {
+		return nil, err
+	}
+	return v.GetDictValue(), nil
+}
+
+// DeepMerge merges src into x with the default MergeOptions and returns
+// the result, see MergeOptions.DeepMerge.
+func (x *Dict) DeepMerge(src *Dict) *Dict {
+	v, _ := MergeOptions{}.DeepMergeDict(x, src)
+	return v
+}
+
+func (o MergeOptions) merge(path string, dst, src *Value) (*Value, error) {
+	if dst == nil {
+		return cloneValue(src), nil
+	}
+	if isNullValue(src) {
+		if o.Nulls == NullIgnore {
+			return cloneValue(dst), nil
+		}
+		return NewNullValue(), nil
+	}
+
+	switch d := dst.GetKind().(type) {
+	case *Value_DictValue:
+		if s, ok := src.GetKind().(*Value_DictValue); ok {
+			x, err := o.mergeDict(path, d.DictValue, s.DictValue)
+			if err != nil {
+				return nil, err
+			}
+			return NewStructValue(x), nil
+		}
+	case *Value_ListValue:
+		if s, ok := src.GetKind().(*Value_ListValue); ok {
+			x, err := o.mergeList(path, d.ListValue, s.ListValue)
+			if err != nil {
+				return nil, err
+			}
+			return NewListValue(x), nil
+		}
+	}
+
+	if o.Conflict != nil && !isNullValue(dst) && !valuesEqual(dst, src) {
+		v, err := o.Conflict(path, dst, src)
+		if err != nil {
+			return nil, err
+		}
+		return cloneValue(v), nil
+	}
+	return cloneValue(src), nil
+}
+
+func (o MergeOptions) mergeDict(path string, dst, src *Dict) (*Dict, error) {
+	x := &Dict{
+		Fields:  make(map[string]*Value, len(dst.GetFields())+len(src.GetFields())),
+		Ordered: dst.GetOrdered(),
+	}
+	for _, k := range dst.Keys() {
+		if _, ok := src.GetFields()[k]; !ok {
+			x.Set(k, cloneValue(dst.Fields[k]))
+			continue
+		}
+		v := src.Fields[k]
+		if isNullValue(v) && o.Nulls == NullDelete {
+			continue
+		}
+		merged, err := o.merge(appendPointer(path, k), dst.Fields[k], v)
+		if err != nil {
+			return nil, err
+		}
+		x.Set(k, merged)
+	}
+
+	for _, k := range src.Keys() {
+		v := src.Fields[k]
+		if _, ok := dst.GetFields()[k]; ok {
+			continue
+		}
+		if isNullValue(v) && o.Nulls != NullOverwrite {
+			continue
+		}
+		x.Set(k, cloneValue(v))
+	}
+	return x, nil
+}
+
+func (o MergeOptions) mergeList(path string, dst, src *List) (*List, error) {
+	switch o.Lists {
+	case ListAppend:
+		x := &List{Values: make([]*Value, 0, len(dst.GetValues())+len(src.GetValues()))}
+		for _, v := range dst.GetValues() {
+			x.Values = append(x.Values, cloneValue(v))
+		}
+		for _, v := range src.GetValues() {
+			x.Values = append(x.Values, cloneValue(v))
+		}
+		return x, nil
+	case ListMergeByKey:
+		x := &List{Values: make([]*Value, 0, len(dst.GetValues())+len(src.GetValues()))}
+		for _, v := range dst.GetValues() {
+			x.Values = append(x.Values, cloneValue(v))
+		}
+		for _, v := range src.GetValues() {
+			i := o.indexByKey(x.Values, v)
+			if i < 0 {
+				x.Values = append(x.Values, cloneValue(v))
+				continue
+			}
+			merged, err := o.merge(appendPointer(path, strconv.Itoa(i)), x.Values[i], v)
+			if err != nil {
+				return nil, err
+			}
+			x.Values[i] = merged
+		}
+		return x, nil
+	default:
+		return src.Clone(), nil
+	}
+}
+
+// indexByKey returns the index of the Dict in values having the same
+// MergeKey field as v, or -1.
+func (o MergeOptions) indexByKey(values []*Value, v *Value) int {
+	key, ok := v.GetDictValue().GetFields()[o.MergeKey]
+	if !ok {
+		return -1
+	}
+	for i, e := range values {
+		if k, ok := e.GetDictValue().GetFields()[o.MergeKey]; ok && valuesEqual(k, key) {
+			return i
+		}
+	}
+	return -1
+}
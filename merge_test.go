@@ -0,0 +1,131 @@
+package structpb
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestMergePatchRFC7396 runs the examples of RFC 7396, appendix A.
+func TestMergePatchRFC7396(t *testing.T) {
+	tests := []struct{ target, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		target := mustParse(t, tt.target)
+		got := MergePatch(target, mustParse(t, tt.patch))
+		if s := toJSON(t, got); s != tt.want {
+			t.Errorf("MergePatch(%s, %s) = %s, want %s", tt.target, tt.patch, s, tt.want)
+		}
+		if s := toJSON(t, target); s != toJSON(t, mustParse(t, tt.target)) {
+			t.Errorf("MergePatch(%s, %s) modified the target to %s", tt.target, tt.patch, s)
+		}
+	}
+}
+
+func TestMergePatchOrdered(t *testing.T) {
+	target := NewOrderedDict()
+	target.Set("z", NewIntValue(1))
+	target.Set("a", NewIntValue(2))
+	patch := NewOrderedDict()
+	patch.Set("m", NewIntValue(3))
+	patch.Set("z", NewNullValue())
+	if got := toJSON(t, target.MergePatch(patch)); got != `{"a":2,"m":3}` {
+		t.Errorf("MergePatch of ordered Dicts = %s", got)
+	}
+}
+
+func TestMergePatchNilDict(t *testing.T) {
+	target := &Dict{Fields: map[string]*Value{"a": NewIntValue(1)}}
+	for _, patch := range []*Dict{nil, {}} {
+		if got := toJSON(t, target.MergePatch(patch)); got != `{"a":1}` {
+			t.Errorf("MergePatch(%v) = %s, want the target unchanged", patch, got)
+		}
+		if got := toJSON(t, MergePatch(NewStructValue(target), NewStructValue(patch))); got != `{"a":1}` {
+			t.Errorf("MergePatch with a %v patch Dict = %s, want the target unchanged", patch, got)
+		}
+	}
+	if got := toJSON(t, MergePatch(NewStructValue(nil), NewStructValue(nil))); got != `{}` {
+		t.Errorf("MergePatch of nil Dicts = %s, want {}", got)
+	}
+}
+
+func TestDeepMerge(t *testing.T) {
+	dst := `{"s":"a","n":null,"d":{"x":1,"y":1},"l":[{"id":1,"v":"a"},{"id":2,"v":"b"}]}`
+	src := `{"s":"b","n":1,"d":{"y":2,"z":null},"l":[{"id":2,"v":"c"},{"id":3,"v":"d"}]}`
+	tests := []struct {
+		name string
+		opts MergeOptions
+		want string
+	}{
+		{"default", MergeOptions{},
+			`{"d":{"x":1,"y":2,"z":null},"l":[{"id":2,"v":"c"},{"id":3,"v":"d"}],"n":1,"s":"b"}`},
+		{"append", MergeOptions{Lists: ListAppend},
+			`{"d":{"x":1,"y":2,"z":null},"l":[{"id":1,"v":"a"},{"id":2,"v":"b"},{"id":2,"v":"c"},{"id":3,"v":"d"}],"n":1,"s":"b"}`},
+		{"merge by key", MergeOptions{Lists: ListMergeByKey, MergeKey: "id"},
+			`{"d":{"x":1,"y":2,"z":null},"l":[{"id":1,"v":"a"},{"id":2,"v":"c"},{"id":3,"v":"d"}],"n":1,"s":"b"}`},
+		{"delete nulls", MergeOptions{Nulls: NullDelete},
+			`{"d":{"x":1,"y":2},"l":[{"id":2,"v":"c"},{"id":3,"v":"d"}],"n":1,"s":"b"}`},
+		{"ignore nulls", MergeOptions{Nulls: NullIgnore},
+			`{"d":{"x":1,"y":2},"l":[{"id":2,"v":"c"},{"id":3,"v":"d"}],"n":1,"s":"b"}`},
+		{"conflict keeps dst", MergeOptions{Conflict: func(path string, dst, src *Value) (*Value, error) {
+			return dst, nil
+		}}, `{"d":{"x":1,"y":1,"z":null},"l":[{"id":2,"v":"c"},{"id":3,"v":"d"}],"n":1,"s":"a"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := mustParse(t, dst)
+			got, err := tt.opts.DeepMerge(d, mustParse(t, src))
+			if err != nil {
+				t.Fatalf("DeepMerge: %v", err)
+			}
+			if s := toJSON(t, got); s != tt.want {
+				t.Errorf("DeepMerge = %s, want %s", s, tt.want)
+			}
+			if s := toJSON(t, d); s != toJSON(t, mustParse(t, dst)) {
+				t.Errorf("DeepMerge modified dst to %s", s)
+			}
+		})
+	}
+}
+
+func TestDeepMergeConflictError(t *testing.T) {
+	errConflict := errors.New("conflict")
+	var paths []string
+	o := MergeOptions{Conflict: func(path string, dst, src *Value) (*Value, error) {
+		paths = append(paths, path)
+		return nil, errConflict
+	}}
+	_, err := o.DeepMerge(mustParse(t, `{"a":{"b/c":1}}`), mustParse(t, `{"a":{"b/c":2}}`))
+	if !errors.Is(err, errConflict) {
+		t.Errorf("DeepMerge error = %v, want the Conflict error", err)
+	}
+	if len(paths) != 1 || paths[0] != "/a/b~1c" {
+		t.Errorf("Conflict paths = %q, want [/a/b~1c]", paths)
+	}
+	// equal values are not a conflict
+	if _, err := o.DeepMerge(mustParse(t, `{"a":1}`), mustParse(t, `{"a":1}`)); err != nil {
+		t.Errorf("DeepMerge of equal values: %v", err)
+	}
+}
+
+func TestDeepMergeDict(t *testing.T) {
+	a := mustParse(t, `{"a":{"x":1}}`).GetDictValue()
+	b := mustParse(t, `{"a":{"y":2}}`).GetDictValue()
+	if got := toJSON(t, a.DeepMerge(b)); got != `{"a":{"x":1,"y":2}}` {
+		t.Errorf("Dict.DeepMerge = %s", got)
+	}
+}
@@ -0,0 +1,941 @@
+package structpb
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/durationpb"
+	wkpb "google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// MessageOptions configures the conversion between Dicts and arbitrary
+// protobuf messages, see FromMessage and ToMessage.
+type MessageOptions struct {
+	// UseProtoNames makes FromMessage use the proto field names as keys
+	// rather than their lowerCamelCase JSON names. ToMessage accepts both.
+	UseProtoNames bool
+
+	// UseEnumNumbers makes FromMessage store enum values as IntValue rather
+	// than as StringValue holding their name.
+	UseEnumNumbers bool
+
+	// EmitUnpopulated makes FromMessage store unset fields with their
+	// default value, null for messages. Unset oneof members and extensions
+	// are still omitted.
+	EmitUnpopulated bool
+
+	// DiscardUnknown makes ToMessage ignore the keys which name no field,
+	// rather than reporting an error.
+	DiscardUnknown bool
+
+	// BigNumbers selects how uint64 values above math.MaxInt64 are stored
+	// by FromMessage, see BigNumberPolicy.
+	BigNumbers BigNumberPolicy
+
+	// Resolver looks up the types of google.protobuf.Any messages and of
+	// extensions. If nil, protoregistry.GlobalTypes is used.
+	Resolver interface {
+		protoregistry.MessageTypeResolver
+		protoregistry.ExtensionTypeResolver
+	}
+
+	// protoJSON makes FromMessage follow the JSON mapping of protobuf more
+	// closely, for MarshalProtoJSON: 64-bit integers are stored as strings
+	// and fields are kept in declaration order.
+	protoJSON bool
+}
+
+// A MessageError describes a value that could not be converted from or to
+// a protobuf message.
+type MessageError struct {
+	// Path is the JSON Pointer of the offending value, "" is the root.
+	Path string
+	Err  error
+}
+
+func (e *MessageError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "root"
+	}
+	return fmt.Sprintf("cannot convert message at %s: %v", path, e.Err)
+}
+
+func (e *MessageError) Unwrap() error { return e.Err }
+
+// FromMessage converts m to a Dict, see MessageOptions.FromMessage.
+func FromMessage(m proto.Message) (*Dict, error) {
+	return MessageOptions{}.FromMessage(m)
+}
+
+// ToMessage populates m from d, see MessageOptions.ToMessage.
+func ToMessage(d *Dict, m proto.Message) error {
+	return MessageOptions{}.ToMessage(d, m)
+}
+
+func (o MessageOptions) resolver() interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+} {
+	if o.Resolver != nil {
+		return o.Resolver
+	}
+	return protoregistry.GlobalTypes
+}
+
+func (o MessageOptions) errorf(path string, format string, args ...interface{}) error {
+	return &MessageError{Path: path, Err: fmt.Errorf(format, args...)}
+}
+
+func (o MessageOptions) mismatch(path string, x *Value, want interface{}) error {
+	return o.errorf(path, "cannot convert %s to %v", kindName(x), want)
+}
+
+// wrapperNames holds the google.protobuf wrapper messages, which are
+// converted to and from the value of their single field.
+var wrapperNames = map[protoreflect.FullName]bool{
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+}
+
+// The full names of the messages of this package, declared in struct.proto,
+// which are converted to and from the Value they hold.
+const (
+	dictName  protoreflect.FullName = "struct.Dict"
+	listName  protoreflect.FullName = "struct.List"
+	valueName protoreflect.FullName = "struct.Value"
+)
+
+// isWellKnownMessage reports whether messages of the given type have their
+// own mapping, and so are held in the "value" key of an Any, like in the
+// JSON mapping of google.protobuf.Any.
+func isWellKnownMessage(name protoreflect.FullName) bool {
+	switch name {
+	case "google.protobuf.Any", "google.protobuf.Timestamp", "google.protobuf.Duration",
+		"google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue",
+		"google.protobuf.Empty", dictName, listName, valueName:
+		return true
+	}
+	return wrapperNames[name]
+}
+
+// FromMessage converts m to a Dict by walking it with protoreflect.
+//
+// Fields are converted like in the JSON mapping of protobuf, except that
+// no precision is lost: 64-bit integers are stored as IntValue rather
+// than strings, bytes as BytesValue, google.protobuf.Timestamp and
+// Duration as TimestampValue and DurationValue. Enums are stored by name,
+// or by number if UseEnumNumbers is set or the number has no name.
+// Wrappers are stored as their value; Struct, Value and ListValue as the
+// equivalent Value; the Dict, List and Value messages of this package as a
+// clone of their Value; and Any as a Dict holding its "@type" and its fields,
+// or its "value" for types with their own mapping. Map keys are formatted
+// like in JSON.
+//
+// Errors are reported as a *MessageError.
+func (o MessageOptions) FromMessage(m proto.Message) (*Dict, error) {
+	pm := m.ProtoReflect()
+	x, err := o.messageValue("", pm)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := x.GetKind().(*Value_DictValue)
+	if !ok {
+		return nil, o.errorf("", "%s does not convert to a Dict", pm.Descriptor().FullName())
+	}
+	return d.DictValue, nil
+}
+
+func (o MessageOptions) messageValue(path string, m protoreflect.Message) (*Value, error) {
+	md := m.Descriptor()
+	fields := md.Fields()
+	switch name := md.FullName(); {
+	case name == "google.protobuf.Timestamp":
+		ts := &timestamppb.Timestamp{
+			Seconds: m.Get(fields.ByNumber(1)).Int(),
+			Nanos:   int32(m.Get(fields.ByNumber(2)).Int()),
+		}
+		if err := ts.CheckValid(); err != nil {
+			return nil, o.errorf(path, "%v", err)
+		}
+		return &Value{Kind: &Value_TimestampValue{TimestampValue: ts}}, nil
+	case name == "google.protobuf.Duration":
+		d := &durationpb.Duration{
+			Seconds: m.Get(fields.ByNumber(1)).Int(),
+			Nanos:   int32(m.Get(fields.ByNumber(2)).Int()),
+		}
+		if err := d.CheckValid(); err != nil {
+			return nil, o.errorf(path, "%v", err)
+		}
+		return &Value{Kind: &Value_DurationValue{DurationValue: d}}, nil
+	case wrapperNames[name]:
+		fd := fields.ByNumber(1)
+		return o.singularValue(path, fd, m.Get(fd))
+	case name == "google.protobuf.Struct":
+		s := &wkpb.Struct{}
+		if err := convertMessage(m.Interface(), s); err != nil {
+			return nil, o.errorf(path, "%v", err)
+		}
+		return NewStructValue(FromWellKnownStruct(s)), nil
+	case name == "google.protobuf.Value":
+		v := &wkpb.Value{}
+		if err := convertMessage(m.Interface(), v); err != nil {
+			return nil, o.errorf(path, "%v", err)
+		}
+		return FromWellKnown(v), nil
+	case name == "google.protobuf.ListValue":
+		l := &wkpb.ListValue{}
+		if err := convertMessage(m.Interface(), l); err != nil {
+			return nil, o.errorf(path, "%v", err)
+		}
+		return NewListValue(FromWellKnownList(l)), nil
+	case name == "google.protobuf.Any":
+		return o.anyValue(path, m)
+	case name == dictName:
+		d := &Dict{}
+		if err := convertMessage(m.Interface(), d); err != nil {
+			return nil, o.errorf(path, "%v", err)
+		}
+		return NewStructValue(d), nil
+	case name == listName:
+		l := &List{}
+		if err := convertMessage(m.Interface(), l); err != nil {
+			return nil, o.errorf(path, "%v", err)
+		}
+		return NewListValue(l), nil
+	case name == valueName:
+		v := &Value{}
+		if err := convertMessage(m.Interface(), v); err != nil {
+			return nil, o.errorf(path, "%v", err)
+		}
+		return v, nil
+	}
+
+	d := &Dict{Fields: map[string]*Value{}}
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		key := o.fieldKey(fd)
+		var x *Value
+		x, err = o.fieldValue(appendPointer(path, key), fd, v)
+		d.Fields[key] = x
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if o.EmitUnpopulated {
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if m.Has(fd) || fd.ContainingOneof() != nil {
+				continue
+			}
+			key := o.fieldKey(fd)
+			if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+				d.Fields[key] = NewNullValue()
+				continue
+			}
+			x, err := o.fieldValue(appendPointer(path, key), fd, m.Get(fd))
+			if err != nil {
+				return nil, err
+			}
+			d.Fields[key] = x
+		}
+	}
+	if o.protoJSON {
+		d.Ordered = true
+		for i := 0; i < fields.Len(); i++ {
+			if key := o.fieldKey(fields.Get(i)); d.Fields[key] != nil {
+				d.KeyOrder = append(d.KeyOrder, key)
+			}
+		}
+	}
+	return NewStructValue(d), nil
+}
+
+func (o MessageOptions) fieldKey(fd protoreflect.FieldDescriptor) string {
+	switch {
+	case fd.IsExtension():
+		return "[" + string(fd.FullName()) + "]"
+	case o.UseProtoNames:
+		return string(fd.Name())
+	default:
+		return fd.JSONName()
+	}
+}
+
+func (o MessageOptions) fieldValue(path string, fd protoreflect.FieldDescriptor, v protoreflect.Value) (*Value, error) {
+	switch {
+	case fd.IsList():
+		l := v.List()
+		list := &List{Values: make([]*Value, l.Len())}
+		for i := 0; i < l.Len(); i++ {
+			x, err := o.singularValue(appendPointer(path, strconv.Itoa(i)), fd, l.Get(i))
+			if err != nil {
+				return nil, err
+			}
+			list.Values[i] = x
+		}
+		return NewListValue(list), nil
+	case fd.IsMap():
+		d := &Dict{Fields: make(map[string]*Value, v.Map().Len())}
+		var err error
+		v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			key := k.String()
+			var x *Value
+			x, err = o.singularValue(appendPointer(path, key), fd.MapValue(), v)
+			d.Fields[key] = x
+			return err == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return NewStructValue(d), nil
+	default:
+		return o.singularValue(path, fd, v)
+	}
+}
+
+func (o MessageOptions) singularValue(path string, fd protoreflect.FieldDescriptor, v protoreflect.Value) (*Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return NewBoolValue(v.Bool()), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return NewIntValue(v.Int()), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if o.protoJSON {
+			return NewStringValue(strconv.FormatInt(v.Int(), 10)), nil
+		}
+		return NewIntValue(v.Int()), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return NewIntValue(int64(v.Uint())), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if o.protoJSON {
+			return NewStringValue(strconv.FormatUint(v.Uint(), 10)), nil
+		}
+		x, err := o.BigNumbers.uintValue(v.Uint())
+		if err != nil {
+			return nil, o.errorf(path, "%v", err)
+		}
+		return x, nil
+	case protoreflect.FloatKind:
+		// use the shortest decimal representation of the float32, like
+		// the JSON mapping, rather than its exact float64 value
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(v.Float(), 'g', -1, 32), 64)
+		return NewFloatValue(f), nil
+	case protoreflect.DoubleKind:
+		return NewFloatValue(v.Float()), nil
+	case protoreflect.StringKind:
+		return NewStringValue(v.String()), nil
+	case protoreflect.BytesKind:
+		return NewBytesValue(append([]byte(nil), v.Bytes()...)), nil
+	case protoreflect.EnumKind:
+		if fd.Enum().FullName() == "google.protobuf.NullValue" {
+			return NewNullValue(), nil
+		}
+		if !o.UseEnumNumbers {
+			if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+				return NewStringValue(string(ev.Name())), nil
+			}
+		}
+		return NewIntValue(int64(v.Enum())), nil
+	default:
+		return o.messageValue(path, v.Message())
+	}
+}
+
+func (o MessageOptions) anyValue(path string, m protoreflect.Message) (*Value, error) {
+	fields := m.Descriptor().Fields()
+	typeURL := m.Get(fields.ByNumber(1)).String()
+	if typeURL == "" && len(m.Get(fields.ByNumber(2)).Bytes()) == 0 {
+		return NewStructValue(&Dict{Fields: map[string]*Value{}}), nil
+	}
+
+	mt, err := o.resolver().FindMessageByURL(typeURL)
+	if err != nil {
+		return nil, o.errorf(path, "cannot resolve Any type %q: %v", typeURL, err)
+	}
+	em := mt.New()
+	err = proto.UnmarshalOptions{AllowPartial: true, Resolver: o.resolver()}.Unmarshal(m.Get(fields.ByNumber(2)).Bytes(), em.Interface())
+	if err != nil {
+		return nil, o.errorf(path, "cannot unmarshal Any of type %q: %v", typeURL, err)
+	}
+
+	var d *Dict
+	if isWellKnownMessage(em.Descriptor().FullName()) {
+		x, err := o.messageValue(appendPointer(path, "value"), em)
+		if err != nil {
+			return nil, err
+		}
+		d = &Dict{Fields: map[string]*Value{"value": x}}
+	} else {
+		x, err := o.messageValue(path, em)
+		if err != nil {
+			return nil, err
+		}
+		d = x.GetDictValue()
+	}
+	d.Fields["@type"] = NewStringValue(typeURL)
+	if o.protoJSON {
+		d.Ordered = true
+		d.KeyOrder = append([]string{"@type"}, d.KeyOrder...)
+	}
+	return NewStructValue(d), nil
+}
+
+// convertMessage copies src into dst, a message of the same type which may
+// have a different implementation, such as a dynamic message.
+func convertMessage(src, dst proto.Message) error {
+	b, err := proto.MarshalOptions{AllowPartial: true}.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return proto.UnmarshalOptions{AllowPartial: true, Merge: true}.Unmarshal(b, dst)
+}
+
+// ToMessage resets m and populates it from d by walking it with
+// protoreflect, the inverse of FromMessage.
+//
+// Keys may be the JSON or the proto names of fields, or the bracketed full
+// names of extensions; keys which name no field are reported unless
+// DiscardUnknown is set. Null values leave fields unset, except for the
+// google.protobuf.Value and NullValue types.
+//
+// Values are converted without loss or reported as an error: integer
+// fields accept integral numbers in range and strings holding them, float
+// fields accept numbers and the strings "NaN", "Infinity" and "-Infinity",
+// bytes fields accept BytesValue and base64 strings, enum fields accept
+// names and numbers, Timestamp and Duration fields accept TimestampValue
+// and DurationValue and the strings of their JSON mapping.
+//
+// Errors are reported as a *MessageError.
+func (o MessageOptions) ToMessage(d *Dict, m proto.Message) error {
+	pm := m.ProtoReflect()
+	if !pm.IsValid() {
+		return &MessageError{Err: fmt.Errorf("invalid nil %T", m)}
+	}
+	proto.Reset(m)
+	if d == nil {
+		d = &Dict{}
+	}
+	return o.setMessage("", NewStructValue(d), pm)
+}
+
+func (o MessageOptions) setMessage(path string, x *Value, m protoreflect.Message) error {
+	md := m.Descriptor()
+	fields := md.Fields()
+	switch name := md.FullName(); {
+	case name == "google.protobuf.Timestamp":
+		var ts *timestamppb.Timestamp
+		switch k := x.GetKind().(type) {
+		case *Value_TimestampValue:
+			ts = k.TimestampValue
+		case *Value_StringValue:
+			t, err := time.Parse(time.RFC3339Nano, k.StringValue)
+			if err != nil {
+				return o.errorf(path, "cannot parse %q as %s", k.StringValue, name)
+			}
+			ts = timestamppb.New(t)
+		default:
+			return o.mismatch(path, x, name)
+		}
+		if err := ts.CheckValid(); err != nil {
+			return o.errorf(path, "%v", err)
+		}
+		m.Set(fields.ByNumber(1), protoreflect.ValueOfInt64(ts.GetSeconds()))
+		m.Set(fields.ByNumber(2), protoreflect.ValueOfInt32(ts.GetNanos()))
+		return nil
+	case name == "google.protobuf.Duration":
+		var d *durationpb.Duration
+		switch k := x.GetKind().(type) {
+		case *Value_DurationValue:
+			d = k.DurationValue
+		case *Value_StringValue:
+			var ok bool
+			if d, ok = parseDuration(k.StringValue); !ok {
+				return o.errorf(path, "cannot parse %q as %s", k.StringValue, name)
+			}
+		default:
+			return o.mismatch(path, x, name)
+		}
+		if err := d.CheckValid(); err != nil {
+			return o.errorf(path, "%v", err)
+		}
+		m.Set(fields.ByNumber(1), protoreflect.ValueOfInt64(d.GetSeconds()))
+		m.Set(fields.ByNumber(2), protoreflect.ValueOfInt32(d.GetNanos()))
+		return nil
+	case wrapperNames[name]:
+		fd := fields.ByNumber(1)
+		v, err := o.scalarFrom(path, fd, x)
+		if err != nil {
+			return err
+		}
+		m.Set(fd, v)
+		return nil
+	case name == "google.protobuf.Struct":
+		d, ok := x.GetKind().(*Value_DictValue)
+		if !ok {
+			return o.mismatch(path, x, name)
+		}
+		s, err := ToWellKnownStruct(d.DictValue)
+		if err != nil {
+			return o.wellKnownError(path, err)
+		}
+		if err := convertMessage(s, m.Interface()); err != nil {
+			return o.errorf(path, "%v", err)
+		}
+		return nil
+	case name == "google.protobuf.Value":
+		v, err := ToWellKnown(x)
+		if err != nil {
+			return o.wellKnownError(path, err)
+		}
+		if err := convertMessage(v, m.Interface()); err != nil {
+			return o.errorf(path, "%v", err)
+		}
+		return nil
+	case name == "google.protobuf.ListValue":
+		l, ok := x.GetKind().(*Value_ListValue)
+		if !ok {
+			return o.mismatch(path, x, name)
+		}
+		wl, err := ToWellKnownList(l.ListValue)
+		if err != nil {
+			return o.wellKnownError(path, err)
+		}
+		if err := convertMessage(wl, m.Interface()); err != nil {
+			return o.errorf(path, "%v", err)
+		}
+		return nil
+	case name == "google.protobuf.Any":
+		return o.setAny(path, x, m)
+	case name == dictName:
+		d, ok := x.GetKind().(*Value_DictValue)
+		if !ok {
+			return o.mismatch(path, x, name)
+		}
+		if err := convertMessage(d.DictValue, m.Interface()); err != nil {
+			return o.errorf(path, "%v", err)
+		}
+		return nil
+	case name == listName:
+		l, ok := x.GetKind().(*Value_ListValue)
+		if !ok {
+			return o.mismatch(path, x, name)
+		}
+		if err := convertMessage(l.ListValue, m.Interface()); err != nil {
+			return o.errorf(path, "%v", err)
+		}
+		return nil
+	case name == valueName:
+		if err := convertMessage(cloneValue(x), m.Interface()); err != nil {
+			return o.errorf(path, "%v", err)
+		}
+		return nil
+	}
+
+	d, ok := x.GetKind().(*Value_DictValue)
+	if !ok {
+		return o.mismatch(path, x, md.FullName())
+	}
+	seen := map[protoreflect.FieldNumber]string{}
+	oneofs := map[protoreflect.Name]string{}
+	for _, key := range d.DictValue.Keys() {
+		v := d.DictValue.Fields[key]
+		fpath := appendPointer(path, key)
+
+		var fd protoreflect.FieldDescriptor
+		if strings.HasPrefix(key, "[") && strings.HasSuffix(key, "]") {
+			xt, err := o.resolver().FindExtensionByName(protoreflect.FullName(key[1 : len(key)-1]))
+			if err == nil && xt.TypeDescriptor().ContainingMessage().FullName() == md.FullName() {
+				fd = xt.TypeDescriptor()
+			}
+		} else if fd = fields.ByJSONName(key); fd == nil {
+			fd = fields.ByName(protoreflect.Name(key))
+		}
+		if fd == nil {
+			if o.DiscardUnknown {
+				continue
+			}
+			return o.errorf(fpath, "unknown field %q in %s", key, md.FullName())
+		}
+		if prev, ok := seen[fd.Number()]; ok {
+			return o.errorf(fpath, "duplicate field %q, also set as %q", key, prev)
+		}
+		seen[fd.Number()] = key
+
+		if isNullValue(v) && !acceptsNull(fd) {
+			continue
+		}
+		if od := fd.ContainingOneof(); od != nil {
+			if prev, ok := oneofs[od.Name()]; ok {
+				return o.errorf(fpath, "oneof %s is already set by %q", od.Name(), prev)
+			}
+			oneofs[od.Name()] = key
+		}
+		if err := o.setField(fpath, fd, v, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// acceptsNull reports whether fd stores null values, rather than being
+// left unset by them.
+func acceptsNull(fd protoreflect.FieldDescriptor) bool {
+	if fd.IsList() || fd.IsMap() {
+		return false
+	}
+	if md := fd.Message(); md != nil {
+		return md.FullName() == "google.protobuf.Value" || md.FullName() == valueName
+	}
+	if ed := fd.Enum(); ed != nil {
+		return ed.FullName() == "google.protobuf.NullValue"
+	}
+	return false
+}
+
+func (o MessageOptions) wellKnownError(path string, err error) error {
+	var we *WellKnownError
+	if errors.As(err, &we) {
+		return &MessageError{Path: path + we.Path, Err: we.Err}
+	}
+	return &MessageError{Path: path, Err: err}
+}
+
+func (o MessageOptions) setField(path string, fd protoreflect.FieldDescriptor, x *Value, m protoreflect.Message) error {
+	switch {
+	case fd.IsList():
+		l, ok := x.GetKind().(*Value_ListValue)
+		if !ok {
+			return o.mismatch(path, x, "list")
+		}
+		list := m.Mutable(fd).List()
+		for i, e := range l.ListValue.GetValues() {
+			v, err := o.singularFrom(appendPointer(path, strconv.Itoa(i)), fd, e, list.NewElement)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+		return nil
+	case fd.IsMap():
+		d, ok := x.GetKind().(*Value_DictValue)
+		if !ok {
+			return o.mismatch(path, x, "map")
+		}
+		mp := m.Mutable(fd).Map()
+		for _, key := range d.DictValue.Keys() {
+			epath := appendPointer(path, key)
+			k, err := o.mapKeyFrom(epath, fd.MapKey(), key)
+			if err != nil {
+				return err
+			}
+			v, err := o.singularFrom(epath, fd.MapValue(), d.DictValue.Fields[key], mp.NewValue)
+			if err != nil {
+				return err
+			}
+			mp.Set(k, v)
+		}
+		return nil
+	default:
+		v, err := o.singularFrom(path, fd, x, func() protoreflect.Value { return m.NewField(fd) })
+		if err != nil {
+			return err
+		}
+		m.Set(fd, v)
+		return nil
+	}
+}
+
+// singularFrom converts x to a single value of fd, newValue returns an
+// empty message for message fields.
+func (o MessageOptions) singularFrom(path string, fd protoreflect.FieldDescriptor, x *Value, newValue func() protoreflect.Value) (protoreflect.Value, error) {
+	if fd.Message() == nil {
+		return o.scalarFrom(path, fd, x)
+	}
+	v := newValue()
+	if err := o.setMessage(path, x, v.Message()); err != nil {
+		return protoreflect.Value{}, err
+	}
+	return v, nil
+}
+
+func (o MessageOptions) mapKeyFrom(path string, fd protoreflect.FieldDescriptor, key string) (protoreflect.MapKey, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(key).MapKey(), nil
+	case protoreflect.BoolKind:
+		switch key {
+		case "true":
+			return protoreflect.ValueOfBool(true).MapKey(), nil
+		case "false":
+			return protoreflect.ValueOfBool(false).MapKey(), nil
+		}
+	default:
+		if v, err := o.scalarFrom(path, fd, NewStringValue(key)); err == nil {
+			return v.MapKey(), nil
+		}
+	}
+	return protoreflect.MapKey{}, o.errorf(path, "invalid map key %q for %v", key, fd.Kind())
+}
+
+func (o MessageOptions) scalarFrom(path string, fd protoreflect.FieldDescriptor, x *Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if k, ok := x.GetKind().(*Value_BoolValue); ok {
+			return protoreflect.ValueOfBool(k.BoolValue), nil
+		}
+	case protoreflect.StringKind:
+		if k, ok := x.GetKind().(*Value_StringValue); ok {
+			return protoreflect.ValueOfString(k.StringValue), nil
+		}
+	case protoreflect.BytesKind:
+		switch k := x.GetKind().(type) {
+		case *Value_BytesValue:
+			return protoreflect.ValueOfBytes(append([]byte(nil), k.BytesValue...)), nil
+		case *Value_StringValue:
+			b, err := decodeBase64(k.StringValue)
+			if err != nil {
+				return protoreflect.Value{}, o.errorf(path, "cannot parse %q as base64", k.StringValue)
+			}
+			return protoreflect.ValueOfBytes(b), nil
+		}
+	case protoreflect.EnumKind:
+		if isNullValue(x) && fd.Enum().FullName() == "google.protobuf.NullValue" {
+			return protoreflect.ValueOfEnum(0), nil
+		}
+		if k, ok := x.GetKind().(*Value_StringValue); ok {
+			ev := fd.Enum().Values().ByName(protoreflect.Name(k.StringValue))
+			if ev == nil {
+				return protoreflect.Value{}, o.errorf(path, "invalid value %q for enum %s", k.StringValue, fd.Enum().FullName())
+			}
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		i, err := o.intFrom(path, fd, x, math.MinInt32, math.MaxInt32)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := o.intFrom(path, fd, x, math.MinInt32, math.MaxInt32)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt32(int32(i)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := o.intFrom(path, fd, x, math.MinInt64, math.MaxInt64)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt64(i), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		u, err := o.uintFrom(path, fd, x, math.MaxUint32)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint32(uint32(u)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		u, err := o.uintFrom(path, fd, x, math.MaxUint64)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint64(u), nil
+	case protoreflect.FloatKind:
+		f, err := o.floatFrom(path, fd, x)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		if !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+			return protoreflect.Value{}, o.errorf(path, "%v overflows %v", f, fd.Kind())
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := o.floatFrom(path, fd, x)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	}
+	return protoreflect.Value{}, o.mismatch(path, x, fd.Kind())
+}
+
+// numberFrom returns the exact value of a number, or of a string holding
+// one in JSON number syntax.
+func (o MessageOptions) numberFrom(path string, fd protoreflect.FieldDescriptor, x *Value) (*big.Rat, error) {
+	switch k := x.GetKind().(type) {
+	case *Value_IntValue, *Value_FloatValue, *Value_DecimalValue:
+		if r := numberRat(x); r != nil {
+			return r, nil
+		}
+		return nil, o.errorf(path, "cannot convert %v to %v", x.AsInterface(), fd.Kind())
+	case *Value_StringValue:
+		if isNumber(k.StringValue) {
+			if r, ok := parseRat(k.StringValue); ok {
+				return r, nil
+			}
+		}
+		return nil, o.errorf(path, "cannot parse %q as %v", k.StringValue, fd.Kind())
+	default:
+		return nil, o.mismatch(path, x, fd.Kind())
+	}
+}
+
+func (o MessageOptions) intFrom(path string, fd protoreflect.FieldDescriptor, x *Value, min, max int64) (int64, error) {
+	r, err := o.numberFrom(path, fd, x)
+	if err != nil {
+		return 0, err
+	}
+	if !r.IsInt() || !r.Num().IsInt64() || r.Num().Int64() < min || r.Num().Int64() > max {
+		return 0, o.errorf(path, "cannot convert %v to %v without loss", x.AsInterface(), fd.Kind())
+	}
+	return r.Num().Int64(), nil
+}
+
+func (o MessageOptions) uintFrom(path string, fd protoreflect.FieldDescriptor, x *Value, max uint64) (uint64, error) {
+	r, err := o.numberFrom(path, fd, x)
+	if err != nil {
+		return 0, err
+	}
+	if !r.IsInt() || !r.Num().IsUint64() || r.Num().Uint64() > max {
+		return 0, o.errorf(path, "cannot convert %v to %v without loss", x.AsInterface(), fd.Kind())
+	}
+	return r.Num().Uint64(), nil
+}
+
+func (o MessageOptions) floatFrom(path string, fd protoreflect.FieldDescriptor, x *Value) (float64, error) {
+	switch k := x.GetKind().(type) {
+	case *Value_FloatValue:
+		return k.FloatValue, nil
+	case *Value_StringValue:
+		if f, ok := parseNonFinite(k.StringValue); ok {
+			return f, nil
+		}
+	}
+	r, err := o.numberFrom(path, fd, x)
+	if err != nil {
+		return 0, err
+	}
+	f, _ := r.Float64()
+	if math.IsInf(f, 0) {
+		return 0, o.errorf(path, "%v overflows %v", x.AsInterface(), fd.Kind())
+	}
+	return f, nil
+}
+
+func (o MessageOptions) setAny(path string, x *Value, m protoreflect.Message) error {
+	d, ok := x.GetKind().(*Value_DictValue)
+	if !ok {
+		return o.mismatch(path, x, "google.protobuf.Any")
+	}
+	if len(d.DictValue.GetFields()) == 0 {
+		return nil
+	}
+	t, ok := d.DictValue.Fields["@type"].GetKind().(*Value_StringValue)
+	if !ok {
+		return o.errorf(path, `missing "@type" string in google.protobuf.Any`)
+	}
+	typeURL := t.StringValue
+	mt, err := o.resolver().FindMessageByURL(typeURL)
+	if err != nil {
+		return o.errorf(path, "cannot resolve Any type %q: %v", typeURL, err)
+	}
+
+	em := mt.New()
+	if isWellKnownMessage(em.Descriptor().FullName()) {
+		for key := range d.DictValue.Fields {
+			if key != "@type" && key != "value" && !o.DiscardUnknown {
+				return o.errorf(appendPointer(path, key), "unknown field %q in google.protobuf.Any", key)
+			}
+		}
+		v, ok := d.DictValue.Fields["value"]
+		if !ok {
+			return o.errorf(path, `missing "value" in google.protobuf.Any`)
+		}
+		err = o.setMessage(appendPointer(path, "value"), v, em)
+	} else {
+		rest := &Dict{Fields: make(map[string]*Value, len(d.DictValue.Fields))}
+		for key, v := range d.DictValue.Fields {
+			if key != "@type" {
+				rest.Fields[key] = v
+			}
+		}
+		err = o.setMessage(path, NewStructValue(rest), em)
+	}
+	if err != nil {
+		return err
+	}
+
+	b, err := proto.MarshalOptions{AllowPartial: true, Deterministic: true}.Marshal(em.Interface())
+	if err != nil {
+		return o.errorf(path, "cannot marshal Any of type %q: %v", typeURL, err)
+	}
+	fields := m.Descriptor().Fields()
+	m.Set(fields.ByNumber(1), protoreflect.ValueOfString(typeURL))
+	m.Set(fields.ByNumber(2), protoreflect.ValueOfBytes(b))
+	return nil
+}
+
+// decodeBase64 decodes s in standard or URL-safe base64, with or without
+// padding, like the JSON mapping of bytes.
+func decodeBase64(s string) ([]byte, error) {
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.URLEncoding
+	}
+	if len(s)%4 != 0 {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	return enc.DecodeString(s)
+}
+
+// parseDuration parses the JSON mapping of google.protobuf.Duration, see
+// formatDuration.
+func parseDuration(s string) (*durationpb.Duration, bool) {
+	if !strings.HasSuffix(s, "s") {
+		return nil, false
+	}
+	s = s[:len(s)-1]
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	secs, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		secs, frac = s[:i], s[i+1:]
+		if frac == "" || len(frac) > 9 {
+			return nil, false
+		}
+	}
+	if secs == "" || strings.Trim(secs, "0123456789") != "" || strings.Trim(frac, "0123456789") != "" {
+		return nil, false
+	}
+	seconds, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	var nanos int64
+	if frac != "" {
+		nanos, _ = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 32)
+	}
+	if neg {
+		seconds, nanos = -seconds, -nanos
+	}
+	return &durationpb.Duration{Seconds: seconds, Nanos: int32(nanos)}, true
+}
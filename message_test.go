@@ -0,0 +1,324 @@
+package structpb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// testMessageFile declares test.Msg, which has a field of every kind that
+// FromMessage and ToMessage convert differently.
+const testMessageFile = `
+name: "test.proto"
+package: "test"
+syntax: "proto3"
+dependency: ["google/protobuf/any.proto", "google/protobuf/duration.proto", "google/protobuf/struct.proto", "google/protobuf/timestamp.proto", "google/protobuf/wrappers.proto", "struct.proto"]
+message_type: {
+	name: "Msg"
+	field: {name: "i32" number: 1 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "i32"}
+	field: {name: "i64" number: 2 label: LABEL_OPTIONAL type: TYPE_INT64 json_name: "i64"}
+	field: {name: "u64" number: 3 label: LABEL_OPTIONAL type: TYPE_UINT64 json_name: "u64"}
+	field: {name: "f32" number: 4 label: LABEL_OPTIONAL type: TYPE_FLOAT json_name: "f32"}
+	field: {name: "f64" number: 5 label: LABEL_OPTIONAL type: TYPE_DOUBLE json_name: "f64"}
+	field: {name: "raw" number: 6 label: LABEL_OPTIONAL type: TYPE_BYTES json_name: "raw"}
+	field: {name: "color" number: 7 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".test.Msg.Color" json_name: "color"}
+	field: {name: "snake_name" number: 8 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "snakeName"}
+	field: {name: "tags" number: 9 label: LABEL_REPEATED type: TYPE_STRING json_name: "tags"}
+	field: {name: "counts" number: 10 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".test.Msg.CountsEntry" json_name: "counts"}
+	field: {name: "children" number: 11 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".test.Msg.ChildrenEntry" json_name: "children"}
+	field: {name: "name" number: 12 label: LABEL_OPTIONAL type: TYPE_STRING oneof_index: 0 json_name: "name"}
+	field: {name: "id" number: 13 label: LABEL_OPTIONAL type: TYPE_INT32 oneof_index: 0 json_name: "id"}
+	field: {name: "created" number: 14 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Timestamp" json_name: "created"}
+	field: {name: "ttl" number: 15 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Duration" json_name: "ttl"}
+	field: {name: "wrapped" number: 16 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Int64Value" json_name: "wrapped"}
+	field: {name: "any" number: 17 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Any" json_name: "any"}
+	field: {name: "extra" number: 18 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Struct" json_name: "extra"}
+	field: {name: "child" number: 19 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".test.Msg" json_name: "child"}
+	field: {name: "config" number: 20 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".struct.Dict" json_name: "config"}
+	nested_type: {
+		name: "CountsEntry"
+		field: {name: "key" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "key"}
+		field: {name: "value" number: 2 label: LABEL_OPTIONAL type: TYPE_INT64 json_name: "value"}
+		options: {map_entry: true}
+	}
+	nested_type: {
+		name: "ChildrenEntry"
+		field: {name: "key" number: 1 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "key"}
+		field: {name: "value" number: 2 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".test.Msg" json_name: "value"}
+		options: {map_entry: true}
+	}
+	enum_type: {
+		name: "Color"
+		value: {name: "RED" number: 0}
+		value: {name: "GREEN" number: 1}
+	}
+	oneof_decl: {name: "choice"}
+}
+`
+
+// testMessageTypes returns a resolver holding test.Msg and the
+// well-known types.
+func testMessageTypes(t *testing.T) *protoregistry.Types {
+	t.Helper()
+	fdp := &descriptorpb.FileDescriptorProto{}
+	if err := prototext.Unmarshal([]byte(testMessageFile), fdp); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := &protoregistry.Types{}
+	for _, m := range []proto.Message{&timestamppb.Timestamp{}, &durationpb.Duration{}, &wrapperspb.Int64Value{}} {
+		if err := types.RegisterMessage(m.ProtoReflect().Type()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := types.RegisterMessage(dynamicpb.NewMessageType(fd.Messages().ByName("Msg"))); err != nil {
+		t.Fatal(err)
+	}
+	return types
+}
+
+// newTestMessage returns an empty test.Msg.
+func newTestMessage(t *testing.T, types *protoregistry.Types) proto.Message {
+	t.Helper()
+	mt, err := types.FindMessageByName("test.Msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mt.New().Interface()
+}
+
+// textMessage returns a test.Msg parsed from the text format s.
+func textMessage(t *testing.T, types *protoregistry.Types, s string) proto.Message {
+	t.Helper()
+	m := newTestMessage(t, types)
+	if err := (prototext.UnmarshalOptions{Resolver: types}).Unmarshal([]byte(s), m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestFromMessage(t *testing.T) {
+	types := testMessageTypes(t)
+	m := textMessage(t, types, `
+i32: -1 i64: 9007199254740993 u64: 18446744073709551615 f32: 0.1 f64: 2.5
+raw: "\x00\xff" color: GREEN snake_name: "s" tags: ["a", "b"]
+counts: {key: "x" value: 3} children: {key: 7 value: {id: 1}}
+name: "n"
+created: {seconds: 1609459200 nanos: 500000000} ttl: {seconds: 90}
+wrapped: {value: 4}
+extra: {fields: {key: "k" value: {list_value: {values: [{bool_value: true}]}}}}
+child: {color: RED}
+`)
+
+	d, err := MessageOptions{Resolver: types, BigNumbers: BigNumberDecimal}.FromMessage(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"child":{},"children":{"7":{"id":1}},"color":"GREEN","counts":{"x":3},` +
+		`"created":"2021-01-01T00:00:00.5Z","extra":{"k":[true]},"f32":0.1,"f64":2.5,` +
+		`"i32":-1,"i64":9007199254740993,"name":"n","raw":"AP8=","snakeName":"s","tags":["a","b"],` +
+		`"ttl":"90s","u64":18446744073709551615,"wrapped":4}`
+	if got := toJSON(t, d); got != want {
+		t.Errorf("FromMessage = %s, want %s", got, want)
+	}
+	kinds := map[string]string{
+		"i64":     "IntValue",
+		"u64":     "DecimalValue",
+		"raw":     "BytesValue",
+		"created": "TimestampValue",
+		"ttl":     "DurationValue",
+		"wrapped": "IntValue",
+	}
+	for k, want := range kinds {
+		if got := kindName(d.Fields[k]); got != want {
+			t.Errorf("FromMessage %s = %s, want %s", k, got, want)
+		}
+	}
+
+	d, err = MessageOptions{Resolver: types, UseProtoNames: true, UseEnumNumbers: true}.FromMessage(
+		textMessage(t, types, `snake_name: "s" color: GREEN`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, d); got != `{"color":1,"snake_name":"s"}` {
+		t.Errorf("FromMessage with UseProtoNames and UseEnumNumbers = %s", got)
+	}
+
+	d, err = MessageOptions{Resolver: types, EmitUnpopulated: true}.FromMessage(textMessage(t, types, ``))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{"i64": `0`, "tags": `[]`, "counts": `{}`, "color": `"RED"`, "child": `null`, "raw": `""`} {
+		if got := toJSON(t, d.Fields[k]); got != want {
+			t.Errorf("FromMessage with EmitUnpopulated %s = %s, want %s", k, got, want)
+		}
+	}
+	if _, ok := d.Fields["name"]; ok {
+		t.Error("FromMessage with EmitUnpopulated emitted an unset oneof member")
+	}
+}
+
+func TestFromMessageAny(t *testing.T) {
+	types := testMessageTypes(t)
+	inner, err := anypb.New(textMessage(t, types, `i64: 5`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wellKnown, err := anypb.New(durationpb.New(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in   *anypb.Any
+		want string
+	}{
+		{inner, `{"@type":"type.googleapis.com/test.Msg","i64":5}`},
+		{wellKnown, `{"@type":"type.googleapis.com/google.protobuf.Duration","value":"1s"}`},
+		{&anypb.Any{}, `{}`},
+	}
+	for _, tt := range tests {
+		m := newTestMessage(t, types)
+		pm := m.ProtoReflect()
+		pm.Set(pm.Descriptor().Fields().ByName("any"), protoreflect.ValueOfMessage(tt.in.ProtoReflect()))
+		d, err := MessageOptions{Resolver: types}.FromMessage(m)
+		if err != nil {
+			t.Errorf("FromMessage with Any %v: %v", tt.in, err)
+			continue
+		}
+		if got := toJSON(t, d.Fields["any"]); got != tt.want {
+			t.Errorf("FromMessage with Any = %s, want %s", got, tt.want)
+		}
+
+		out := newTestMessage(t, types)
+		if err := (MessageOptions{Resolver: types}).ToMessage(d, out); err != nil {
+			t.Errorf("ToMessage(%s): %v", toJSON(t, d), err)
+		} else if !proto.Equal(out, m) {
+			t.Errorf("ToMessage(%s) = %v, want %v", toJSON(t, d), out, m)
+		}
+	}
+
+	unresolved := &anypb.Any{TypeUrl: "type.googleapis.com/test.Unknown"}
+	if _, err := FromMessage(unresolved); err == nil {
+		t.Error("FromMessage of an Any with an unknown type succeeded")
+	}
+}
+
+func TestToMessage(t *testing.T) {
+	types := testMessageTypes(t)
+	o := MessageOptions{Resolver: types}
+	tests := []struct {
+		json string
+		want string
+	}{
+		{`{"i32":-1,"i64":"9007199254740993","u64":"18446744073709551615"}`, `i32: -1 i64: 9007199254740993 u64: 18446744073709551615`},
+		{`{"f32":"NaN","f64":1}`, `f32: nan f64: 1`},
+		{`{"raw":"AP8","color":1}`, `raw: "\x00\xff" color: GREEN`},
+		{`{"snake_name":"a","tags":["x"],"counts":{"k":"2"}}`, `snake_name: "a" tags: "x" counts: {key: "k" value: 2}`},
+		{`{"children":{"-3":{"color":"GREEN"}}}`, `children: {key: -3 value: {color: GREEN}}`},
+		{`{"id":3,"name":null}`, `id: 3`},
+		{`{"created":"2021-01-01T01:00:00+01:00","ttl":"-1.5s","wrapped":"7"}`, `created: {seconds: 1609459200} ttl: {seconds: -1 nanos: -500000000} wrapped: {value: 7}`},
+		{`{"extra":{"a":[1,null]},"child":{"child":{}}}`, `extra: {fields: {key: "a" value: {list_value: {values: [{number_value: 1}, {null_value: NULL_VALUE}]}}}} child: {child: {}}`},
+	}
+	for _, tt := range tests {
+		m := newTestMessage(t, types)
+		if err := o.ToMessage(mustParse(t, tt.json).GetDictValue(), m); err != nil {
+			t.Errorf("ToMessage(%s): %v", tt.json, err)
+			continue
+		}
+		if want := textMessage(t, types, tt.want); !proto.Equal(m, want) {
+			t.Errorf("ToMessage(%s) = %v, want %v", tt.json, m, want)
+		}
+	}
+}
+
+func TestToMessageErrors(t *testing.T) {
+	types := testMessageTypes(t)
+	tests := []struct {
+		json string
+		path string
+	}{
+		{`{"nope":1}`, "/nope"},
+		{`{"i32":2147483648}`, "/i32"},
+		{`{"i64":1.5}`, "/i64"},
+		{`{"u64":-1}`, "/u64"},
+		{`{"i64":"1e5000000"}`, "/i64"},
+		{`{"u64":"1e5000000"}`, "/u64"},
+		{`{"f64":"1e5000000"}`, "/f64"},
+		{`{"i32":"1e-1000000"}`, "/i32"},
+		{`{"color":"BLUE"}`, "/color"},
+		{`{"raw":"!"}`, "/raw"},
+		{`{"tags":"a"}`, "/tags"},
+		{`{"counts":{"k":[]}}`, "/counts/k"},
+		{`{"children":{"x":{}}}`, "/children/x"},
+		{`{"name":"a","id":1}`, "/name"},
+		{`{"snakeName":"a","snake_name":"b"}`, "/snake_name"},
+		{`{"created":"yesterday"}`, "/created"},
+		{`{"child":{"child":{"i32":"x"}}}`, "/child/child/i32"},
+		{`{"extra":{"a":[9007199254740993]}}`, "/extra/a/0"},
+		{`{"any":{"@type":"type.googleapis.com/test.Unknown"}}`, "/any"},
+	}
+	for _, tt := range tests {
+		err := MessageOptions{Resolver: types}.ToMessage(mustParse(t, tt.json).GetDictValue(), newTestMessage(t, types))
+		var me *MessageError
+		if !errors.As(err, &me) {
+			t.Errorf("ToMessage(%s) error = %v, want a *MessageError", tt.json, err)
+			continue
+		}
+		if me.Path != tt.path {
+			t.Errorf("ToMessage(%s) error path = %q, want %q", tt.json, me.Path, tt.path)
+		}
+	}
+
+	// invalid UTF-8 only fails once the Struct or Dict is encoded
+	invalid := NewStructValue(&Dict{Fields: map[string]*Value{"a": NewStringValue("\xff")}})
+	for _, key := range []string{"extra", "config"} {
+		d := &Dict{Fields: map[string]*Value{key: invalid}}
+		err := MessageOptions{Resolver: types}.ToMessage(d, newTestMessage(t, types))
+		var me *MessageError
+		if !errors.As(err, &me) || me.Path != "/"+key {
+			t.Errorf("ToMessage of invalid UTF-8 in %s = %v, want a *MessageError at /%s", key, err, key)
+		}
+	}
+
+	m := newTestMessage(t, types)
+	if err := (MessageOptions{Resolver: types, DiscardUnknown: true}).ToMessage(mustParse(t, `{"nope":1,"i32":2}`).GetDictValue(), m); err != nil {
+		t.Errorf("ToMessage with DiscardUnknown: %v", err)
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	types := testMessageTypes(t)
+	o := MessageOptions{Resolver: types}
+	m := textMessage(t, types, `
+i64: -9223372036854775808 u64: 9223372036854775807 f32: 1e-3 raw: "x" color: GREEN
+tags: ["a"] counts: {key: "c" value: -1} children: {key: 1 value: {name: "c"}}
+created: {seconds: -1 nanos: 1} ttl: {nanos: 1} wrapped: {value: 0}
+extra: {} child: {id: 0}
+`)
+	d, err := o.FromMessage(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := newTestMessage(t, types)
+	if err := o.ToMessage(d, out); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(out, m) {
+		t.Errorf("round trip through %s = %v, want %v", toJSON(t, d), out, m)
+	}
+}
@@ -0,0 +1,135 @@
+package structpb
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// BigNumberPolicy defines how numbers that cannot be stored exactly as an
+// IntValue or a FloatValue are converted: integers outside of the int64
+// range and, for *big.Float, values that are not exact float64.
+type BigNumberPolicy int
+
+const (
+	// BigNumberFloat stores them as FloatValue, rounded to the nearest
+	// float64. This is the default.
+	BigNumberFloat BigNumberPolicy = iota
+	// BigNumberError reports an error.
+	BigNumberError
+	// BigNumberString stores their decimal representation as StringValue.
+	BigNumberString
+	// BigNumberDecimal stores them exactly as DecimalValue.
+	BigNumberDecimal
+)
+
+// bigNumber converts s, the JSON number representation of a number which
+// cannot be stored exactly, according to p.
+func (p BigNumberPolicy) bigNumber(s string) (*Value, error) {
+	switch p {
+	case BigNumberError:
+		return nil, fmt.Errorf("number %s cannot be represented exactly", s)
+	case BigNumberString:
+		return NewStringValue(s), nil
+	case BigNumberDecimal:
+		return &Value{Kind: &Value_DecimalValue{DecimalValue: s}}, nil
+	default:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("number %s overflows float64", s)
+		}
+		return NewFloatValue(f), nil
+	}
+}
+
+// numberValue converts s, which must be in JSON number syntax, to an
+// IntValue if it is an integer, and to a FloatValue otherwise.
+func (p BigNumberPolicy) numberValue(s string) (*Value, error) {
+	if isIntegralNumber([]byte(s)) {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return NewIntValue(i), nil
+		}
+		return p.bigNumber(s)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("number %s overflows float64", s)
+	}
+	return NewFloatValue(f), nil
+}
+
+func (p BigNumberPolicy) uintValue(u uint64) (*Value, error) {
+	if u > math.MaxInt64 {
+		return p.bigNumber(strconv.FormatUint(u, 10))
+	}
+	return NewIntValue(int64(u)), nil
+}
+
+func (p BigNumberPolicy) bigIntValue(i *big.Int) (*Value, error) {
+	if i.IsInt64() {
+		return NewIntValue(i.Int64()), nil
+	}
+	return p.bigNumber(i.String())
+}
+
+func (p BigNumberPolicy) bigFloatValue(f *big.Float) (*Value, error) {
+	if f.IsInf() {
+		return nil, fmt.Errorf("number %v cannot be represented", f)
+	}
+	if f.IsInt() {
+		if i, acc := f.Int64(); acc == big.Exact {
+			return NewIntValue(i), nil
+		}
+	}
+	if v, acc := f.Float64(); acc == big.Exact {
+		return NewFloatValue(v), nil
+	}
+	return p.bigNumber(f.Text('g', -1))
+}
+
+// isNumber reports whether s is in JSON number syntax.
+func isNumber(s string) bool {
+	d := jsonDecoder{data: []byte(s)}
+	_, err := d.readNumber()
+	return err == nil && d.pos == len(s)
+}
+
+// numberRat returns the exact value of a finite IntValue, FloatValue or
+// DecimalValue, or nil if x is not a finite number.
+func numberRat(x *Value) *big.Rat {
+	switch v := x.GetKind().(type) {
+	case *Value_IntValue:
+		return new(big.Rat).SetInt64(v.IntValue)
+	case *Value_FloatValue:
+		if math.IsNaN(v.FloatValue) || math.IsInf(v.FloatValue, 0) {
+			return nil
+		}
+		return new(big.Rat).SetFloat64(v.FloatValue)
+	case *Value_DecimalValue:
+		r, ok := parseRat(v.DecimalValue)
+		if !ok {
+			return nil
+		}
+		return r
+	}
+	return nil
+}
+
+// maxRatExponent bounds the exponent accepted by parseRat: big.Rat spends
+// time and memory proportional to it, and no field a number converts to
+// needs more.
+const maxRatExponent = 10000
+
+// parseRat parses s as an exact number, reporting false if it is not one
+// or if its exponent is beyond ±maxRatExponent.
+func parseRat(s string) (*big.Rat, bool) {
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil || exp > maxRatExponent || exp < -maxRatExponent {
+			return nil, false
+		}
+	}
+	return new(big.Rat).SetString(s)
+}
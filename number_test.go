@@ -0,0 +1,91 @@
+package structpb
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestUnmarshalBigNumbers(t *testing.T) {
+	const big = `18446744073709551615`
+	tests := []struct {
+		policy BigNumberPolicy
+		kind   string
+		want   string // JSON output, "" if an error is expected
+	}{
+		{BigNumberFloat, "FloatValue", `18446744073709552000`},
+		{BigNumberError, "", ``},
+		{BigNumberString, "StringValue", `"18446744073709551615"`},
+		{BigNumberDecimal, "DecimalValue", `18446744073709551615`},
+	}
+	for _, tt := range tests {
+		x := &Value{}
+		err := UnmarshalOptions{BigNumbers: tt.policy}.Unmarshal([]byte(big), x)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Unmarshal with policy %d = %v, want an error", tt.policy, x)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal with policy %d: %v", tt.policy, err)
+			continue
+		}
+		if kindName(x) != tt.kind {
+			t.Errorf("Unmarshal with policy %d = %s, want %s", tt.policy, kindName(x), tt.kind)
+		}
+		if got := toJSON(t, x); got != tt.want {
+			t.Errorf("Unmarshal with policy %d marshals as %s, want %s", tt.policy, got, tt.want)
+		}
+	}
+
+	// numbers in the int64 range and fractions are not affected
+	x := &Value{}
+	if err := (UnmarshalOptions{BigNumbers: BigNumberError}).Unmarshal([]byte(`[9223372036854775807, 1.5]`), x); err != nil {
+		t.Errorf("Unmarshal of small numbers with BigNumberError: %v", err)
+	}
+}
+
+func TestDecimalValue(t *testing.T) {
+	for _, s := range []string{"0", "-1", "18446744073709551615", "1.25e-400", "3.14159265358979323846264338327950288"} {
+		x, err := NewDecimalValue(s)
+		if err != nil {
+			t.Errorf("NewDecimalValue(%s): %v", s, err)
+			continue
+		}
+		if got := toJSON(t, x); got != s {
+			t.Errorf("NewDecimalValue(%s) marshals as %s", s, got)
+		}
+		if got := x.AsInterface(); got != json.Number(s) {
+			t.Errorf("NewDecimalValue(%s).AsInterface() = %v", s, got)
+		}
+		y := &Value{}
+		if err := (UnmarshalOptions{BigNumbers: BigNumberDecimal}).Unmarshal([]byte(toJSON(t, x)), y); err != nil {
+			t.Errorf("Unmarshal(%s): %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "1.", ".5", "01", "+1", "1e", "NaN", "0x10", " 1"} {
+		if _, err := NewDecimalValue(s); err == nil {
+			t.Errorf("NewDecimalValue(%q) succeeded", s)
+		}
+	}
+}
+
+func TestDecodeDecimal(t *testing.T) {
+	x, err := NewDecimalValue("18446744073709551615")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var u uint64
+	if err := Decode(x, &u); err != nil || u != 18446744073709551615 {
+		t.Errorf("Decode into uint64 = %d, %v", u, err)
+	}
+	var i int64
+	if err := Decode(x, &i); err == nil {
+		t.Errorf("Decode into int64 = %d, want an overflow error", i)
+	}
+	var b big.Int
+	if err := Decode(x, &b); err != nil || b.String() != "18446744073709551615" {
+		t.Errorf("Decode into big.Int = %s, %v", b.String(), err)
+	}
+}
@@ -0,0 +1,491 @@
+package structpb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrTestFailed is returned when a "test" operation of a JSON Patch fails.
+var ErrTestFailed = errors.New("test failed")
+
+// A PatchOperation is a single operation of a JSON Patch (RFC 6902).
+type PatchOperation struct {
+	// Op is one of "add", "remove", "replace", "move", "copy" or "test".
+	Op string
+	// Path is the JSON Pointer of the target location.
+	Path string
+	// From is the JSON Pointer of the source location of "move" and "copy".
+	From string
+	// Value is the value of "add", "replace" and "test".
+	Value *Value
+}
+
+type patchOperationJSON struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	j := patchOperationJSON{Op: op.Op, Path: &op.Path}
+	switch op.Op {
+	case "move", "copy":
+		j.From = &op.From
+	case "add", "replace", "test":
+		// floats keep their kind, so the patch decoded back applies the
+		// same values
+		b, err := MarshalOptions{PreserveNumberKind: true}.Marshal(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		j.Value = b
+	}
+	return json.Marshal(j)
+}
+
+func (op *PatchOperation) UnmarshalJSON(p []byte) error {
+	var j patchOperationJSON
+	if err := json.Unmarshal(p, &j); err != nil {
+		return err
+	}
+	if j.Path == nil {
+		return fmt.Errorf("invalid patch operation %q: missing path", j.Op)
+	}
+
+	*op = PatchOperation{Op: j.Op, Path: *j.Path}
+	switch j.Op {
+	case "add", "replace", "test":
+		if j.Value == nil {
+			return fmt.Errorf("invalid patch operation %q: missing value", j.Op)
+		}
+		op.Value = &Value{}
+		if err := op.Value.UnmarshalJSON(j.Value); err != nil {
+			return err
+		}
+	case "move", "copy":
+		if j.From == nil {
+			return fmt.Errorf("invalid patch operation %q: missing from", j.Op)
+		}
+		op.From = *j.From
+	case "remove":
+	default:
+		return fmt.Errorf("invalid patch operation %q", j.Op)
+	}
+	return nil
+}
+
+// A Patch is a JSON Patch (RFC 6902) document.
+type Patch []PatchOperation
+
+// DecodePatch parses a JSON Patch document.
+func DecodePatch(p []byte) (Patch, error) {
+	var patch Patch
+	if err := json.Unmarshal(p, &patch); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// A PatchError describes the operation of a Patch that could not be applied.
+type PatchError struct {
+	// Index is the position of the operation in the Patch.
+	Index int
+	Op    PatchOperation
+	Err   error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("patch operation %d (%s %q): %v", e.Index, e.Op.Op, e.Op.Path, e.Err)
+}
+
+func (e *PatchError) Unwrap() error { return e.Err }
+
+// PatchOptions is a configurable JSON Patch applier.
+type PatchOptions struct {
+	// NumericEquivalence makes the "test" operation compare IntValue and
+	// FloatValue by their numeric value, so IntValue(1) equals FloatValue(1).
+	// By default, numbers of different kinds are never equal.
+	NumericEquivalence bool
+}
+
+func (o PatchOptions) equalOptions() []EqualOption {
+	opts := []EqualOption{NaNEqual, NilEqualsEmpty}
+	if o.NumericEquivalence {
+		opts = append(opts, NumericEquivalence)
+	}
+	return opts
+}
+
+// Apply applies the patch to doc, see PatchOptions.Apply.
+func (p Patch) Apply(doc *Value) error {
+	return PatchOptions{}.Apply(doc, p)
+}
+
+// ApplyPatch applies the patch to x, see PatchOptions.Apply.
+// The patch must leave the document as a Dict.
+func (x *Dict) ApplyPatch(p Patch) error {
+	return PatchOptions{}.ApplyDict(x, p)
+}
+
+// Apply applies the patch to doc atomically: if any operation fails,
+// doc is left unmodified and the error is a *PatchError.
+func (o PatchOptions) Apply(doc *Value, p Patch) error {
+	if doc == nil {
+		return &PatchError{Index: -1, Err: ErrNotFound}
+	}
+	result, err := o.apply(doc.Clone(), p)
+	if err != nil {
+		return err
+	}
+	doc.Kind = result.Kind
+	return nil
+}
+
+// ApplyDict applies the patch to x atomically, see Apply.
+// The patch must leave the document as a Dict.
+func (o PatchOptions) ApplyDict(x *Dict, p Patch) error {
+	if x == nil {
+		return &PatchError{Index: -1, Err: ErrNotFound}
+	}
+	result, err := o.apply(NewStructValue(x.Clone()), p)
+	if err != nil {
+		return err
+	}
+	d, ok := result.GetKind().(*Value_DictValue)
+	if !ok {
+		return &PatchError{Index: len(p) - 1, Op: p[len(p)-1], Err: fmt.Errorf("%w: result is %s", ErrTypeMismatch, kindName(result))}
+	}
+	x.replace(d.DictValue)
+	return nil
+}
+
+func (o PatchOptions) apply(doc *Value, p Patch) (*Value, error) {
+	for i, op := range p {
+		var err error
+		doc, err = o.applyOperation(doc, op)
+		if err != nil {
+			return nil, &PatchError{Index: i, Op: op, Err: err}
+		}
+	}
+	return doc, nil
+}
+
+func (o PatchOptions) applyOperation(doc *Value, op PatchOperation) (*Value, error) {
+	switch op.Op {
+	case "add":
+		if op.Value == nil {
+			return nil, errors.New("missing value")
+		}
+		return patchAdd(doc, op.Path, op.Value.Clone())
+	case "remove":
+		_, err := doc.removeAt(op.Path)
+		return doc, err
+	case "replace":
+		if op.Value == nil {
+			return nil, errors.New("missing value")
+		}
+		return patchReplace(doc, op.Path, op.Value.Clone())
+	case "move":
+		if op.From == op.Path {
+			_, err := doc.At(op.From)
+			return doc, err
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("%w: cannot move %q into its child %q", ErrInvalidPointer, op.From, op.Path)
+		}
+		v, err := doc.removeAt(op.From)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, op.Path, v)
+	case "copy":
+		v, err := doc.At(op.From)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, op.Path, cloneValue(v))
+	case "test":
+		if op.Value == nil {
+			return nil, errors.New("missing value")
+		}
+		v, err := doc.At(op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !Equal(v, op.Value, o.equalOptions()...) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// patchAdd implements the "add" operation: unlike SetAt, intermediate
+// values must exist, and List elements are inserted rather than replaced.
+func patchAdd(doc *Value, pointer string, v *Value) (*Value, error) {
+	w, err := newPointerWalker(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(w.tokens) == 0 {
+		return v, nil
+	}
+
+	last := len(w.tokens) - 1
+	parent, err := w.resolve(doc, last, false)
+	if err != nil {
+		return nil, err
+	}
+	token := w.tokens[last]
+
+	switch k := parent.GetKind().(type) {
+	case *Value_DictValue:
+		if k.DictValue == nil {
+			k.DictValue = &Dict{}
+		}
+		k.DictValue.Set(token, v)
+	case *Value_ListValue:
+		if k.ListValue == nil {
+			k.ListValue = &List{}
+		}
+		values := k.ListValue.Values
+		index := len(values)
+		if token != "-" {
+			index, err = parseIndex(token)
+			if err != nil {
+				return nil, w.errorAt(last, err)
+			}
+			if index > len(values) {
+				return nil, w.errorAt(last, fmt.Errorf("%w: %d > %d", ErrIndexOutOfRange, index, len(values)))
+			}
+		}
+		values = append(values, nil)
+		copy(values[index+1:], values[index:])
+		values[index] = v
+		k.ListValue.Values = values
+	default:
+		return nil, w.errorAt(last, fmt.Errorf("%w: cannot index into %s", ErrTypeMismatch, kindName(parent)))
+	}
+	return doc, nil
+}
+
+// patchReplace implements the "replace" operation: the value must exist,
+// and is replaced where it is, so a key of an ordered Dict keeps its
+// position.
+func patchReplace(doc *Value, pointer string, v *Value) (*Value, error) {
+	w, err := newPointerWalker(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(w.tokens) == 0 {
+		return v, nil
+	}
+
+	last := len(w.tokens) - 1
+	parent, err := w.resolve(doc, last, false)
+	if err != nil {
+		return nil, err
+	}
+	token := w.tokens[last]
+
+	switch k := parent.GetKind().(type) {
+	case *Value_DictValue:
+		if _, ok := k.DictValue.GetFields()[token]; !ok {
+			return nil, w.errorAt(last, ErrNotFound)
+		}
+		k.DictValue.Set(token, v)
+	case *Value_ListValue:
+		values := k.ListValue.GetValues()
+		index, err := parseIndex(token)
+		if err != nil {
+			return nil, w.errorAt(last, err)
+		}
+		if index >= len(values) {
+			return nil, w.errorAt(last, fmt.Errorf("%w: %d >= %d", ErrIndexOutOfRange, index, len(values)))
+		}
+		values[index] = v
+	default:
+		return nil, w.errorAt(last, fmt.Errorf("%w: cannot index into %s", ErrTypeMismatch, kindName(parent)))
+	}
+	return doc, nil
+}
+
+// CreatePatch returns a JSON Patch that transforms a into b.
+//
+// Dicts are compared field by field, and new keys are added in the order
+// of b's Keys, so an ordered b is reproduced. Lists are compared with a shortest
+// edit script over their elements, so the patch has the fewest operations
+// on each List: elements are removed, added, or, when one takes the place
+// of another, patched in place by their own operations. To bound the
+// cost, Lists whose changed parts have lengths with a product over 2^20
+// are compared element by element.
+//
+// Numbers of different kinds are always replaced, so applying the patch
+// to a yields a value with the same kinds as b. This holds after a JSON
+// round trip too, as patch values are written with PreserveNumberKind.
+func CreatePatch(a, b *Value) Patch {
+	var p Patch
+	return appendPatch(p, "", a, b)
+}
+
+func appendPatch(p Patch, path string, a, b *Value) Patch {
+	if valuesEqual(a, b) {
+		return p
+	}
+
+	switch av := a.GetKind().(type) {
+	case *Value_DictValue:
+		bv, ok := b.GetKind().(*Value_DictValue)
+		if !ok {
+			break
+		}
+		for _, k := range av.DictValue.sortedKeys() {
+			if w, ok := bv.DictValue.GetFields()[k]; ok {
+				p = appendPatch(p, appendPointer(path, k), av.DictValue.Fields[k], w)
+			} else {
+				p = append(p, PatchOperation{Op: "remove", Path: appendPointer(path, k)})
+			}
+		}
+		for _, k := range bv.DictValue.Keys() {
+			if _, ok := av.DictValue.GetFields()[k]; !ok {
+				p = append(p, PatchOperation{Op: "add", Path: appendPointer(path, k), Value: cloneValue(bv.DictValue.Fields[k])})
+			}
+		}
+		return p
+	case *Value_ListValue:
+		bv, ok := b.GetKind().(*Value_ListValue)
+		if !ok {
+			break
+		}
+		editList(av.ListValue.GetValues(), bv.ListValue.GetValues(), func(op listEditOp, index int, x, y *Value) {
+			at := appendPointer(path, strconv.Itoa(index))
+			switch op {
+			case listEditPatch:
+				p = appendPatch(p, at, x, y)
+			case listEditRemove:
+				p = append(p, PatchOperation{Op: "remove", Path: at})
+			case listEditAdd:
+				p = append(p, PatchOperation{Op: "add", Path: at, Value: cloneValue(y)})
+			}
+		})
+		return p
+	}
+
+	return append(p, PatchOperation{Op: "replace", Path: path, Value: cloneValue(b)})
+}
+
+// maxEditCells bounds the size of the table computed by editList.
+const maxEditCells = 1 << 20
+
+// A listEditOp is a step of the edit script computed by editList.
+type listEditOp int
+
+const (
+	// listEditPatch changes an element in place into another one.
+	listEditPatch listEditOp = iota
+	// listEditRemove removes an element.
+	listEditRemove
+	// listEditAdd inserts an element.
+	listEditAdd
+)
+
+// editList calls edit with each step of a shortest edit script from as to
+// bs, where an element is removed, added or changed in place, and equal
+// elements are kept. index is the position of the step in the List being
+// edited, once the previous steps are applied; x is the element of as
+// changed or removed, and y the element of bs it is changed into or
+// added. CreatePatch and Diff both use it, so they align Lists the same
+// way.
+func editList(as, bs []*Value, edit func(op listEditOp, index int, x, y *Value)) {
+	start := 0
+	for start < len(as) && start < len(bs) && valuesEqual(as[start], bs[start]) {
+		start++
+	}
+	ea, eb := len(as), len(bs)
+	for ea > start && eb > start && valuesEqual(as[ea-1], bs[eb-1]) {
+		ea--
+		eb--
+	}
+	as, bs = as[start:ea], bs[start:eb]
+
+	n, m := len(as), len(bs)
+	if n*m > maxEditCells {
+		editListByPosition(start, as, bs, edit)
+		return
+	}
+
+	// cost[i*(m+1)+j] is the number of edits from as[i:] to bs[j:]
+	w := m + 1
+	cost := make([]int, (n+1)*w)
+	equal := make([]bool, n*m)
+	for i := n; i >= 0; i-- {
+		for j := m; j >= 0; j-- {
+			switch {
+			case i == n:
+				cost[i*w+j] = m - j
+			case j == m:
+				cost[i*w+j] = n - i
+			case valuesEqual(as[i], bs[j]):
+				equal[i*m+j] = true
+				cost[i*w+j] = cost[(i+1)*w+j+1]
+			default:
+				c := cost[(i+1)*w+j+1]
+				if d := cost[(i+1)*w+j]; d < c {
+					c = d
+				}
+				if d := cost[i*w+j+1]; d < c {
+					c = d
+				}
+				cost[i*w+j] = c + 1
+			}
+		}
+	}
+
+	index := start
+	for i, j := 0, 0; i < n || j < m; {
+		switch {
+		case i < n && j < m && equal[i*m+j]:
+			index, i, j = index+1, i+1, j+1
+		case i < n && j < m && cost[i*w+j] == cost[(i+1)*w+j+1]+1:
+			edit(listEditPatch, index, as[i], bs[j])
+			index, i, j = index+1, i+1, j+1
+		case i < n && (j == m || cost[i*w+j] == cost[(i+1)*w+j]+1):
+			edit(listEditRemove, index, as[i], nil)
+			i++
+		default:
+			edit(listEditAdd, index, nil, bs[j])
+			index, j = index+1, j+1
+		}
+	}
+}
+
+// editListByPosition is editList comparing the elements by position: the
+// first ones are changed in place, the others removed or added.
+func editListByPosition(start int, as, bs []*Value, edit func(op listEditOp, index int, x, y *Value)) {
+	common := len(as)
+	if len(bs) < common {
+		common = len(bs)
+	}
+	for i := 0; i < common; i++ {
+		edit(listEditPatch, start+i, as[i], bs[i])
+	}
+	for i := common; i < len(as); i++ {
+		edit(listEditRemove, start+common, as[i], nil)
+	}
+	for i := common; i < len(bs); i++ {
+		edit(listEditAdd, start+i, nil, bs[i])
+	}
+}
+
+// cloneValue is like x.Clone, but returns a NullValue for nil
+func cloneValue(x *Value) *Value {
+	if x == nil {
+		return NewNullValue()
+	}
+	return x.Clone()
+}
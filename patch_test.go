@@ -0,0 +1,209 @@
+package structpb
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// TestPatchRFC6902 runs the examples of RFC 6902, appendix A.
+func TestPatchRFC6902(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string // "" if the patch must fail
+	}{
+		{"A.1 add object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"A.2 add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"A.3 remove object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"A.4 remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"A.5 replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"A.6 move value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"A.7 move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"A.8 test success", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"A.9 test error", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``},
+		{"A.10 add nested member", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"child":{"grandchild":{}},"foo":"bar"}`},
+		{"A.12 add to nonexistent target", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``},
+		{"A.14 escape ordering", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`},
+		{"A.15 compare strings and numbers", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, ``},
+		{"A.16 add array value", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{"copy", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"}]`, `{"a":{"b":1},"c":{"b":1}}`},
+		{"replace root", `{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+		{"remove missing", `{"a":1}`, `[{"op":"remove","path":"/b"}]`, ``},
+		{"replace missing", `{"a":1}`, `[{"op":"replace","path":"/b","value":1}]`, ``},
+		{"move into itself", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/c"}]`, ``},
+		{"add past the end", `[1]`, `[{"op":"add","path":"/2","value":1}]`, ``},
+		{"test int against float", `{"a":1}`, `[{"op":"test","path":"/a","value":1.0}]`, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := DecodePatch([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("DecodePatch: %v", err)
+			}
+			doc := mustParse(t, tt.doc)
+			err = p.Apply(doc)
+			if tt.want == "" {
+				var pe *PatchError
+				if !errors.As(err, &pe) {
+					t.Fatalf("Apply error = %v, want a *PatchError", err)
+				}
+				if got := toJSON(t, doc); got != toJSON(t, mustParse(t, tt.doc)) {
+					t.Errorf("failed Apply modified the document to %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got := toJSON(t, doc); got != tt.want {
+				t.Errorf("Apply = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchAtomic(t *testing.T) {
+	doc := mustParse(t, `{"a":1}`)
+	p := Patch{
+		{Op: "add", Path: "/b", Value: NewIntValue(2)},
+		{Op: "remove", Path: "/missing"},
+	}
+	err := p.Apply(doc)
+	var pe *PatchError
+	if !errors.As(err, &pe) || pe.Index != 1 || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Apply error = %v, want a *PatchError at index 1 wrapping ErrNotFound", err)
+	}
+	if got := toJSON(t, doc); got != `{"a":1}` {
+		t.Errorf("failed Apply modified the document to %s", got)
+	}
+}
+
+func TestPatchNumericEquivalence(t *testing.T) {
+	p := Patch{{Op: "test", Path: "/a", Value: NewFloatValue(1)}}
+	if err := (PatchOptions{NumericEquivalence: true}).Apply(mustParse(t, `{"a":1}`), p); err != nil {
+		t.Errorf("Apply with NumericEquivalence: %v", err)
+	}
+}
+
+func TestApplyDict(t *testing.T) {
+	d := mustParse(t, `{"a":1}`).GetDictValue()
+	if err := d.ApplyPatch(Patch{{Op: "add", Path: "/b", Value: NewIntValue(2)}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, d); got != `{"a":1,"b":2}` {
+		t.Errorf("ApplyPatch = %s", got)
+	}
+	if err := d.ApplyPatch(Patch{{Op: "replace", Path: "", Value: NewIntValue(1)}}); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("ApplyPatch leaving a non-Dict error = %v, want ErrTypeMismatch", err)
+	}
+}
+
+func TestDecodePatchErrors(t *testing.T) {
+	for _, p := range []string{
+		`{}`,
+		`[{"op":"unknown","path":"/a"}]`,
+		`[{"op":"add","path":"/a"}]`,
+		`[{"op":"move","path":"/a"}]`,
+		`[{"path":"/a"}]`,
+	} {
+		if _, err := DecodePatch([]byte(p)); err == nil {
+			t.Errorf("DecodePatch(%s) succeeded", p)
+		}
+	}
+}
+
+func TestCreatePatch(t *testing.T) {
+	tests := []struct {
+		a, b string
+		ops  int
+	}{
+		{`{"a":1}`, `{"a":1}`, 0},
+		{`{"a":1,"b":2}`, `{"a":1,"c":3}`, 2},
+		{`{"a":{"b":[1,2,3,4]}}`, `{"a":{"b":[1,5,4]}}`, 2},
+		{`[1,2,3]`, `[0,1,2,3]`, 1},
+		{`[1,2,3]`, `[1,2]`, 1},
+		{`[1,2,3,4]`, `[1,3,4,5]`, 2},
+		{`[1,2,3,4,5,6]`, `[6,2,4,7,5]`, 4},
+		{`["a","b","c"]`, `["c","b","a"]`, 2},
+		{`[{"id":1,"v":1},2]`, `[{"id":1,"v":2},2,3]`, 2},
+		{`[]`, `[1,2]`, 2},
+		{`{"n":1}`, `{"n":1.0}`, 1},
+		{`{"a":[]}`, `{"a":{}}`, 1},
+		{`1`, `"x"`, 1},
+		{`{"a/b":{"~":1}}`, `{"a/b":{"~":2}}`, 1},
+	}
+	for _, tt := range tests {
+		a, b := mustParse(t, tt.a), mustParse(t, tt.b)
+		p := CreatePatch(a, b)
+		if len(p) != tt.ops {
+			t.Errorf("CreatePatch(%s, %s) = %d operations %v, want %d", tt.a, tt.b, len(p), p, tt.ops)
+		}
+
+		// the patch gives the same kinds once encoded and decoded
+		j, err := json.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodePatch(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := mustParse(t, tt.a)
+		if err := decoded.Apply(c); err != nil || !Equal(c, b) {
+			t.Errorf("CreatePatch(%s, %s) through JSON %s applied = %s, %v", tt.a, tt.b, j, toJSON(t, c), err)
+		}
+
+		if err := p.Apply(a); err != nil {
+			t.Errorf("CreatePatch(%s, %s) does not apply: %v", tt.a, tt.b, err)
+			continue
+		}
+		if !Equal(a, b) {
+			t.Errorf("CreatePatch(%s, %s) applied = %s", tt.a, tt.b, toJSON(t, a))
+		}
+	}
+}
+
+func TestCreatePatchLargeList(t *testing.T) {
+	a, b := &List{}, &List{}
+	for i := 0; i < 2000; i++ {
+		a.Values = append(a.Values, NewIntValue(int64(i)))
+		b.Values = append(b.Values, NewIntValue(int64(i+1)))
+	}
+	b.Values[1000] = NewStringValue("x")
+	x := NewListValue(a)
+	if err := CreatePatch(x, NewListValue(b)).Apply(x); err != nil || !Equal(x, NewListValue(b)) {
+		t.Errorf("CreatePatch of large Lists does not apply: %v", err)
+	}
+}
+
+func TestPatchReplaceOrdered(t *testing.T) {
+	d := NewOrderedDict()
+	d.Set("z", NewIntValue(1))
+	d.Set("a", NewIntValue(2))
+	d.Set("m", NewIntValue(3))
+	p := Patch{{Op: "replace", Path: "/z", Value: NewIntValue(4)}}
+	if err := d.ApplyPatch(p); err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, d); got != `{"z":4,"a":2,"m":3}` {
+		t.Errorf("replace in an ordered Dict = %s, want the key kept in place", got)
+	}
+}
+
+func TestCreatePatchOrdered(t *testing.T) {
+	a, b := NewOrderedDict(), NewOrderedDict()
+	a.Set("z", NewIntValue(1))
+	a.Set("a", NewIntValue(2))
+	for _, k := range []string{"z", "a", "y", "b", "x"} {
+		b.Set(k, NewIntValue(3))
+	}
+	p := CreatePatch(NewStructValue(a), NewStructValue(b))
+	if err := a.ApplyPatch(p); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := toJSON(t, a), toJSON(t, b); got != want {
+		t.Errorf("ordered Dict patched = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,404 @@
+package structpb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPointer is returned for a malformed JSON Pointer or List index.
+	ErrInvalidPointer = errors.New("invalid JSON pointer")
+	// ErrNotFound is returned when a Dict has no field for a reference token.
+	ErrNotFound = errors.New("not found")
+	// ErrIndexOutOfRange is returned when a List index is past its end.
+	ErrIndexOutOfRange = errors.New("index out of range")
+	// ErrTypeMismatch is returned when a reference token points into a value
+	// which is neither a Dict nor a List.
+	ErrTypeMismatch = errors.New("type mismatch")
+)
+
+// A PointerError describes a JSON Pointer that could not be resolved.
+type PointerError struct {
+	// Pointer is the full JSON Pointer being resolved.
+	Pointer string
+	// Path is the prefix of Pointer where the error occurred.
+	Path string
+	Err  error
+}
+
+func (e *PointerError) Error() string {
+	return fmt.Sprintf("json pointer %q: %v at %q", e.Pointer, e.Err, e.Path)
+}
+
+func (e *PointerError) Unwrap() error { return e.Err }
+
+// ParsePointer splits a JSON Pointer (RFC 6901) into its unescaped
+// reference tokens. The empty pointer refers to the whole document and
+// has no tokens.
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, &PointerError{Pointer: pointer, Err: fmt.Errorf("%w: must start with '/'", ErrInvalidPointer)}
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		if !strings.Contains(token, "~") {
+			continue
+		}
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, &PointerError{Pointer: pointer, Err: fmt.Errorf("%w: bad escape in %q", ErrInvalidPointer, token)}
+			}
+		}
+		token = strings.ReplaceAll(token, "~1", "/")
+		token = strings.ReplaceAll(token, "~0", "~")
+		tokens[i] = token
+	}
+	return tokens, nil
+}
+
+// FormatPointer joins unescaped reference tokens into a JSON Pointer.
+func FormatPointer(tokens []string) string {
+	var pointer string
+	for _, token := range tokens {
+		pointer = appendPointer(pointer, token)
+	}
+	return pointer
+}
+
+// appendPointer appends an unescaped reference token to a JSON Pointer.
+func appendPointer(path string, token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	token = strings.ReplaceAll(token, "/", "~1")
+	return path + "/" + token
+}
+
+// parseIndex parses a List index token, "-" is not accepted.
+func parseIndex(token string) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: bad index %q", ErrInvalidPointer, token)
+	}
+	for i := 0; i < len(token); i++ {
+		if token[i] < '0' || token[i] > '9' {
+			return 0, fmt.Errorf("%w: bad index %q", ErrInvalidPointer, token)
+		}
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("%w: bad index %q", ErrInvalidPointer, token)
+	}
+	return i, nil
+}
+
+// pointerWalker resolves the tokens of a single pointer.
+type pointerWalker struct {
+	pointer string
+	tokens  []string
+}
+
+func newPointerWalker(pointer string) (*pointerWalker, error) {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return &pointerWalker{pointer: pointer, tokens: tokens}, nil
+}
+
+func (w *pointerWalker) errorAt(i int, err error) error {
+	return &PointerError{Pointer: w.pointer, Path: FormatPointer(w.tokens[:i+1]), Err: err}
+}
+
+// child returns the child of x referenced by the token at i.
+// If create is true, missing or null children are replaced by empty
+// containers suitable for the token at i+1.
+func (w *pointerWalker) child(x *Value, i int, create bool) (*Value, error) {
+	token := w.tokens[i]
+
+	switch k := x.GetKind().(type) {
+	case *Value_DictValue:
+		if k.DictValue == nil {
+			if !create {
+				return nil, w.errorAt(i, ErrNotFound)
+			}
+			k.DictValue = &Dict{}
+		}
+		c, ok := k.DictValue.GetFields()[token]
+		if !ok || (create && isNullValue(c)) {
+			if !create {
+				return nil, w.errorAt(i, ErrNotFound)
+			}
+			c = w.newContainer(i + 1)
+			k.DictValue.Set(token, c)
+		}
+		if c == nil {
+			// a nil field is a null value
+			c = NewNullValue()
+		}
+		return c, nil
+	case *Value_ListValue:
+		if k.ListValue == nil && create {
+			k.ListValue = &List{}
+		}
+		values := k.ListValue.GetValues()
+		if token == "-" {
+			if !create {
+				return nil, w.errorAt(i, fmt.Errorf("%w: %q refers past the end", ErrIndexOutOfRange, token))
+			}
+			c := w.newContainer(i + 1)
+			k.ListValue.Values = append(values, c)
+			return c, nil
+		}
+		index, err := parseIndex(token)
+		if err != nil {
+			return nil, w.errorAt(i, err)
+		}
+		if index >= len(values) {
+			return nil, w.errorAt(i, fmt.Errorf("%w: %d >= %d", ErrIndexOutOfRange, index, len(values)))
+		}
+		c := values[index]
+		if create && isNullValue(c) {
+			c = w.newContainer(i + 1)
+			values[index] = c
+		}
+		if c == nil {
+			c = NewNullValue()
+		}
+		return c, nil
+	default:
+		return nil, w.errorAt(i, fmt.Errorf("%w: cannot index into %s", ErrTypeMismatch, kindName(x)))
+	}
+}
+
+// newContainer creates an empty List if the token at i is "-",
+// otherwise an empty Dict: a numeric token such as "0" is a valid Dict
+// key, so it does not imply a List.
+func (w *pointerWalker) newContainer(i int) *Value {
+	if i < len(w.tokens) && w.tokens[i] == "-" {
+		return NewListValue(&List{})
+	}
+	return NewStructValue(&Dict{Fields: map[string]*Value{}})
+}
+
+// resolve returns the value referenced by the first n tokens.
+func (w *pointerWalker) resolve(x *Value, n int, create bool) (*Value, error) {
+	if x == nil {
+		return nil, &PointerError{Pointer: w.pointer, Err: ErrNotFound}
+	}
+	for i := 0; i < n; i++ {
+		var err error
+		x, err = w.child(x, i, create)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+func isNullValue(x *Value) bool {
+	switch x.GetKind().(type) {
+	case *Value_NullValue, nil:
+		return true
+	}
+	return false
+}
+
+// At returns the value referenced by the JSON Pointer (RFC 6901).
+// The returned value is shared with x, not a copy.
+//
+// The error is a *PointerError wrapping ErrInvalidPointer, ErrNotFound,
+// ErrIndexOutOfRange or ErrTypeMismatch.
+func (x *Value) At(pointer string) (*Value, error) {
+	w, err := newPointerWalker(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return w.resolve(x, len(w.tokens), false)
+}
+
+// Exists reports whether the JSON Pointer refers to a value in x.
+func (x *Value) Exists(pointer string) bool {
+	_, err := x.At(pointer)
+	return err == nil
+}
+
+// SetAt sets the value referenced by the JSON Pointer (RFC 6901).
+//
+// A Dict field is added or replaced. A List element is replaced, or
+// appended if the index is "-" or the length of the List.
+// Missing or null intermediate values are created as an empty Dict,
+// or as an empty List when the next reference token is "-", so setting
+// "/a/0" where a is missing sets {"a": {"0": v}}. This is unlike the
+// "add" operation of JSON Patch (RFC 6902), which fails when the parent
+// is missing, see Patch.Apply.
+// The empty pointer replaces the content of x itself.
+func (x *Value) SetAt(pointer string, v *Value) error {
+	w, err := newPointerWalker(pointer)
+	if err != nil {
+		return err
+	}
+	if x == nil {
+		return &PointerError{Pointer: pointer, Err: ErrNotFound}
+	}
+	if len(w.tokens) == 0 {
+		x.Kind = v.GetKind()
+		return nil
+	}
+
+	last := len(w.tokens) - 1
+	parent, err := w.resolve(x, last, true)
+	if err != nil {
+		return err
+	}
+	token := w.tokens[last]
+
+	switch k := parent.GetKind().(type) {
+	case *Value_DictValue:
+		if k.DictValue == nil {
+			k.DictValue = &Dict{}
+		}
+		k.DictValue.Set(token, v)
+		return nil
+	case *Value_ListValue:
+		if k.ListValue == nil {
+			k.ListValue = &List{}
+		}
+		if token == "-" {
+			k.ListValue.Values = append(k.ListValue.Values, v)
+			return nil
+		}
+		index, err := parseIndex(token)
+		if err != nil {
+			return w.errorAt(last, err)
+		}
+		switch {
+		case index < len(k.ListValue.Values):
+			k.ListValue.Values[index] = v
+		case index == len(k.ListValue.Values):
+			k.ListValue.Values = append(k.ListValue.Values, v)
+		default:
+			return w.errorAt(last, fmt.Errorf("%w: %d > %d", ErrIndexOutOfRange, index, len(k.ListValue.Values)))
+		}
+		return nil
+	default:
+		return w.errorAt(last, fmt.Errorf("%w: cannot index into %s", ErrTypeMismatch, kindName(parent)))
+	}
+}
+
+// DeleteAt removes the value referenced by the JSON Pointer (RFC 6901).
+// Removing a List element shifts the following elements down.
+// The empty pointer cannot be deleted.
+func (x *Value) DeleteAt(pointer string) error {
+	_, err := x.removeAt(pointer)
+	return err
+}
+
+// removeAt is DeleteAt that also returns the removed value.
+func (x *Value) removeAt(pointer string) (*Value, error) {
+	w, err := newPointerWalker(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(w.tokens) == 0 {
+		return nil, &PointerError{Pointer: pointer, Err: fmt.Errorf("%w: cannot delete the root", ErrInvalidPointer)}
+	}
+
+	last := len(w.tokens) - 1
+	parent, err := w.resolve(x, last, false)
+	if err != nil {
+		return nil, err
+	}
+	token := w.tokens[last]
+
+	switch k := parent.GetKind().(type) {
+	case *Value_DictValue:
+		old, ok := k.DictValue.GetFields()[token]
+		if !ok {
+			return nil, w.errorAt(last, ErrNotFound)
+		}
+		k.DictValue.Delete(token)
+		return old, nil
+	case *Value_ListValue:
+		values := k.ListValue.GetValues()
+		index, err := parseIndex(token)
+		if err != nil {
+			return nil, w.errorAt(last, err)
+		}
+		if index >= len(values) {
+			return nil, w.errorAt(last, fmt.Errorf("%w: %d >= %d", ErrIndexOutOfRange, index, len(values)))
+		}
+		old := values[index]
+		k.ListValue.Values = append(values[:index], values[index+1:]...)
+		return old, nil
+	default:
+		return nil, w.errorAt(last, fmt.Errorf("%w: cannot index into %s", ErrTypeMismatch, kindName(parent)))
+	}
+}
+
+// At returns the value referenced by the JSON Pointer, see Value.At.
+func (x *Dict) At(pointer string) (*Value, error) {
+	return NewStructValue(x).At(pointer)
+}
+
+// Exists reports whether the JSON Pointer refers to a value in x.
+func (x *Dict) Exists(pointer string) bool {
+	return NewStructValue(x).Exists(pointer)
+}
+
+// SetAt sets the value referenced by the JSON Pointer, see Value.SetAt.
+// The empty pointer replaces the fields of x, and v must be a DictValue.
+func (x *Dict) SetAt(pointer string, v *Value) error {
+	if x == nil {
+		return &PointerError{Pointer: pointer, Err: ErrNotFound}
+	}
+	if pointer == "" {
+		d, ok := v.GetKind().(*Value_DictValue)
+		if !ok {
+			return &PointerError{Pointer: pointer, Err: fmt.Errorf("%w: cannot replace Dict with %s", ErrTypeMismatch, kindName(v))}
+		}
+		x.replace(d.DictValue)
+		return nil
+	}
+	return NewStructValue(x).SetAt(pointer, v)
+}
+
+// DeleteAt removes the value referenced by the JSON Pointer, see Value.DeleteAt.
+func (x *Dict) DeleteAt(pointer string) error {
+	return NewStructValue(x).DeleteAt(pointer)
+}
+
+// At returns the value referenced by the JSON Pointer, see Value.At.
+func (x *List) At(pointer string) (*Value, error) {
+	return NewListValue(x).At(pointer)
+}
+
+// Exists reports whether the JSON Pointer refers to a value in x.
+func (x *List) Exists(pointer string) bool {
+	return NewListValue(x).Exists(pointer)
+}
+
+// SetAt sets the value referenced by the JSON Pointer, see Value.SetAt.
+// The empty pointer replaces the values of x, and v must be a ListValue.
+func (x *List) SetAt(pointer string, v *Value) error {
+	if x == nil {
+		return &PointerError{Pointer: pointer, Err: ErrNotFound}
+	}
+	if pointer == "" {
+		l, ok := v.GetKind().(*Value_ListValue)
+		if !ok {
+			return &PointerError{Pointer: pointer, Err: fmt.Errorf("%w: cannot replace List with %s", ErrTypeMismatch, kindName(v))}
+		}
+		x.Values = l.ListValue.GetValues()
+		return nil
+	}
+	return NewListValue(x).SetAt(pointer, v)
+}
+
+// DeleteAt removes the value referenced by the JSON Pointer, see Value.DeleteAt.
+func (x *List) DeleteAt(pointer string) error {
+	return NewListValue(x).DeleteAt(pointer)
+}
@@ -0,0 +1,241 @@
+package structpb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		pointer string
+		want    []string
+		err     bool
+	}{
+		{"", nil, false},
+		{"/", []string{""}, false},
+		{"/a/b", []string{"a", "b"}, false},
+		{"/a~1b/m~0n", []string{"a/b", "m~n"}, false},
+		{"/~01", []string{"~1"}, false},
+		{"a", nil, true},
+		{"/a~", nil, true},
+		{"/a~2", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePointer(tt.pointer)
+		if (err != nil) != tt.err {
+			t.Errorf("ParsePointer(%q) error = %v, want error %v", tt.pointer, err, tt.err)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidPointer) {
+				t.Errorf("ParsePointer(%q) error = %v, want ErrInvalidPointer", tt.pointer, err)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePointer(%q) = %q, want %q", tt.pointer, got, tt.want)
+		}
+		if f := FormatPointer(got); f != tt.pointer {
+			t.Errorf("FormatPointer(%q) = %q, want %q", got, f, tt.pointer)
+		}
+	}
+}
+
+// rfc6901Doc is the example document of RFC 6901, section 5.
+const rfc6901Doc = `{
+	"foo": ["bar", "baz"],
+	"": 0,
+	"a/b": 1,
+	"c%d": 2,
+	"e^f": 3,
+	"g|h": 4,
+	"i\\j": 5,
+	"k\"l": 6,
+	" ": 7,
+	"m~n": 8
+}`
+
+func TestAtRFC6901(t *testing.T) {
+	doc := mustParse(t, rfc6901Doc)
+	tests := []struct {
+		pointer string
+		want    string
+	}{
+		{"", toJSON(t, doc)},
+		{"/foo", `["bar","baz"]`},
+		{"/foo/0", `"bar"`},
+		{"/", `0`},
+		{"/a~1b", `1`},
+		{"/c%d", `2`},
+		{"/e^f", `3`},
+		{"/g|h", `4`},
+		{"/i\\j", `5`},
+		{"/k\"l", `6`},
+		{"/ ", `7`},
+		{"/m~0n", `8`},
+	}
+	for _, tt := range tests {
+		v, err := doc.At(tt.pointer)
+		if err != nil {
+			t.Errorf("At(%q): %v", tt.pointer, err)
+			continue
+		}
+		if got := toJSON(t, v); got != tt.want {
+			t.Errorf("At(%q) = %s, want %s", tt.pointer, got, tt.want)
+		}
+	}
+}
+
+func TestAtErrors(t *testing.T) {
+	doc := mustParse(t, `{"a": {"b": [1, null]}, "s": "x"}`)
+	tests := []struct {
+		pointer string
+		err     error
+		path    string
+	}{
+		{"/missing", ErrNotFound, "/missing"},
+		{"/a/c", ErrNotFound, "/a/c"},
+		{"/a/b/2", ErrIndexOutOfRange, "/a/b/2"},
+		{"/a/b/-", ErrIndexOutOfRange, "/a/b/-"},
+		{"/a/b/01", ErrInvalidPointer, "/a/b/01"},
+		{"/a/b/x", ErrInvalidPointer, "/a/b/x"},
+		{"/s/0", ErrTypeMismatch, "/s/0"},
+		{"/a/b/1/x", ErrTypeMismatch, "/a/b/1/x"},
+	}
+	for _, tt := range tests {
+		_, err := doc.At(tt.pointer)
+		var pe *PointerError
+		if !errors.As(err, &pe) || !errors.Is(err, tt.err) {
+			t.Errorf("At(%q) error = %v, want a *PointerError wrapping %v", tt.pointer, err, tt.err)
+			continue
+		}
+		if pe.Path != tt.path {
+			t.Errorf("At(%q) error path = %q, want %q", tt.pointer, pe.Path, tt.path)
+		}
+		if doc.Exists(tt.pointer) {
+			t.Errorf("Exists(%q) = true", tt.pointer)
+		}
+	}
+	if !doc.Exists("/a/b/1") {
+		t.Error(`Exists("/a/b/1") = false for a null element`)
+	}
+}
+
+func TestAtDoesNotModify(t *testing.T) {
+	x := &Value{Kind: &Value_ListValue{}}
+	if x.Exists("/0") {
+		t.Error(`Exists("/0") = true in a nil List`)
+	}
+	if x.GetListValue() != nil {
+		t.Error("Exists allocated a List")
+	}
+	d := &Value{Kind: &Value_DictValue{}}
+	if _, err := d.At("/a/b"); err == nil {
+		t.Error(`At("/a/b") succeeded in a nil Dict`)
+	}
+	if d.GetDictValue() != nil {
+		t.Error("At allocated a Dict")
+	}
+}
+
+func TestSetAt(t *testing.T) {
+	tests := []struct {
+		doc     string
+		pointer string
+		value   string
+		want    string
+		err     error
+	}{
+		{`{}`, "/a", `1`, `{"a":1}`, nil},
+		{`{"a":1}`, "/a", `2`, `{"a":2}`, nil},
+		{`{}`, "/a/b/c", `1`, `{"a":{"b":{"c":1}}}`, nil},
+		{`{}`, "/a/0", `1`, `{"a":{"0":1}}`, nil},
+		{`{}`, "/a/-", `1`, `{"a":[1]}`, nil},
+		{`{"a":null}`, "/a/b", `1`, `{"a":{"b":1}}`, nil},
+		{`[1,2]`, "/0", `0`, `[0,2]`, nil},
+		{`[1,2]`, "/2", `3`, `[1,2,3]`, nil},
+		{`[1,2]`, "/-", `3`, `[1,2,3]`, nil},
+		{`[1,2]`, "/3", `3`, ``, ErrIndexOutOfRange},
+		{`[1,2]`, "/x", `3`, ``, ErrInvalidPointer},
+		{`{"a":"s"}`, "/a/b", `1`, ``, ErrTypeMismatch},
+		{`{"a":1}`, "", `[true]`, `[true]`, nil},
+	}
+	for _, tt := range tests {
+		doc := mustParse(t, tt.doc)
+		err := doc.SetAt(tt.pointer, mustParse(t, tt.value))
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("SetAt(%s, %q) error = %v, want %v", tt.doc, tt.pointer, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SetAt(%s, %q): %v", tt.doc, tt.pointer, err)
+			continue
+		}
+		if got := toJSON(t, doc); got != tt.want {
+			t.Errorf("SetAt(%s, %q) = %s, want %s", tt.doc, tt.pointer, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteAt(t *testing.T) {
+	tests := []struct {
+		doc     string
+		pointer string
+		want    string
+		err     error
+	}{
+		{`{"a":1,"b":2}`, "/a", `{"b":2}`, nil},
+		{`{"a":{"b":[1,2,3]}}`, "/a/b/1", `{"a":{"b":[1,3]}}`, nil},
+		{`{"a":1}`, "/b", ``, ErrNotFound},
+		{`[1]`, "/1", ``, ErrIndexOutOfRange},
+		{`[1]`, "/-", ``, ErrInvalidPointer},
+		{`{}`, "", ``, ErrInvalidPointer},
+	}
+	for _, tt := range tests {
+		doc := mustParse(t, tt.doc)
+		err := doc.DeleteAt(tt.pointer)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("DeleteAt(%s, %q) error = %v, want %v", tt.doc, tt.pointer, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("DeleteAt(%s, %q): %v", tt.doc, tt.pointer, err)
+			continue
+		}
+		if got := toJSON(t, doc); got != tt.want {
+			t.Errorf("DeleteAt(%s, %q) = %s, want %s", tt.doc, tt.pointer, got, tt.want)
+		}
+	}
+}
+
+func TestPointerOnDictAndList(t *testing.T) {
+	d := NewEmptyDict()
+	if err := d.SetAt("/a/b", NewIntValue(1)); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.At("/a/b"); err != nil || v.GetIntValue() != 1 {
+		t.Errorf(`Dict.At("/a/b") = %v, %v`, v, err)
+	}
+	if err := d.SetAt("", NewIntValue(1)); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf(`Dict.SetAt("", int) error = %v, want ErrTypeMismatch`, err)
+	}
+	if err := d.DeleteAt("/a"); err != nil || d.Exists("/a") {
+		t.Errorf(`Dict.DeleteAt("/a") = %v`, err)
+	}
+
+	l := NewEmptyList()
+	if err := l.SetAt("/-", NewStringValue("x")); err != nil {
+		t.Fatal(err)
+	}
+	if !l.Exists("/0") || l.Exists("/1") {
+		t.Errorf("List after append = %s", toJSON(t, l))
+	}
+	if err := l.SetAt("", NewListValue(NewEmptyList())); err != nil || len(l.Values) != 0 {
+		t.Errorf(`List.SetAt("", []) = %v, %s`, err, toJSON(t, l))
+	}
+}
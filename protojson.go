@@ -0,0 +1,77 @@
+package structpb
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// MarshalProtoJSON writes m, any message, in the JSON format of protobuf
+// according to the protojson options o, writing the Dict, List and Value
+// messages of this package as plain JSON values rather than as messages.
+// It is a replacement for o.Marshal when m has fields of these types.
+//
+// The options Multiline, Indent, AllowPartial, UseProtoNames,
+// UseEnumNumbers, EmitUnpopulated and Resolver are honored. Messages are
+// converted as by MessageOptions.FromMessage, except that 64-bit integer
+// fields are written as strings and fields in declaration order, like
+// protojson does.
+func MarshalProtoJSON(o protojson.MarshalOptions, m proto.Message) ([]byte, error) {
+	if !o.AllowPartial {
+		if err := proto.CheckInitialized(m); err != nil {
+			return nil, err
+		}
+	}
+
+	mo := MessageOptions{
+		UseProtoNames:   o.UseProtoNames,
+		UseEnumNumbers:  o.UseEnumNumbers,
+		EmitUnpopulated: o.EmitUnpopulated,
+		Resolver:        o.Resolver,
+		protoJSON:       true,
+	}
+	x, err := mo.messageValue("", m.ProtoReflect())
+	if err != nil {
+		return nil, err
+	}
+
+	jo := MarshalOptions{Indent: o.Indent}
+	if o.Multiline && jo.Indent == "" {
+		jo.Indent = "  "
+	}
+	return jo.Marshal(x)
+}
+
+// UnmarshalProtoJSON reads m, any message, from the JSON format of
+// protobuf according to the protojson options o, reading the Dict, List
+// and Value messages of this package from plain JSON values. It is a
+// replacement for o.Unmarshal when m has fields of these types.
+//
+// The options AllowPartial, DiscardUnknown and Resolver are honored.
+// Messages are populated as by MessageOptions.ToMessage. Like protojson,
+// duplicate keys are rejected with a *DuplicateKeyError.
+func UnmarshalProtoJSON(o protojson.UnmarshalOptions, b []byte, m proto.Message) error {
+	x := &Value{}
+	if err := (UnmarshalOptions{BigNumbers: BigNumberDecimal, DuplicateKeys: DuplicateKeyReject}).Unmarshal(b, x); err != nil {
+		return err
+	}
+
+	pm := m.ProtoReflect()
+	if !pm.IsValid() {
+		return &MessageError{Err: fmt.Errorf("invalid nil %T", m)}
+	}
+	proto.Reset(m)
+	mo := MessageOptions{
+		DiscardUnknown: o.DiscardUnknown,
+		Resolver:       o.Resolver,
+	}
+	if err := mo.setMessage("", x, pm); err != nil {
+		return err
+	}
+
+	if !o.AllowPartial {
+		return proto.CheckInitialized(m)
+	}
+	return nil
+}
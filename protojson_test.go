@@ -0,0 +1,102 @@
+package structpb
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestMarshalProtoJSON(t *testing.T) {
+	types := testMessageTypes(t)
+	m := textMessage(t, types, `
+i64: 9007199254740993 color: GREEN snake_name: "s"
+config: {fields: {key: "b" value: {int_value: 2}} fields: {key: "a" value: {list_value: {values: [{float_value: 0.5}]}}}}
+`)
+	tests := []struct {
+		name string
+		opts protojson.MarshalOptions
+		want string
+	}{
+		{"default", protojson.MarshalOptions{Resolver: types}, `{"i64":"9007199254740993","color":"GREEN","snakeName":"s","config":{"a":[0.5],"b":2}}`},
+		{"proto names and enum numbers", protojson.MarshalOptions{Resolver: types, UseProtoNames: true, UseEnumNumbers: true}, `{"i64":"9007199254740993","color":1,"snake_name":"s","config":{"a":[0.5],"b":2}}`},
+		{"multiline", protojson.MarshalOptions{Resolver: types, Multiline: true}, "{\n  \"i64\": \"9007199254740993\",\n  \"color\": \"GREEN\",\n  \"snakeName\": \"s\",\n  \"config\": {\n    \"a\": [\n      0.5\n    ],\n    \"b\": 2\n  }\n}"},
+		{"indent", protojson.MarshalOptions{Resolver: types, Indent: "\t"}, "{\n\t\"i64\": \"9007199254740993\",\n\t\"color\": \"GREEN\",\n\t\"snakeName\": \"s\",\n\t\"config\": {\n\t\t\"a\": [\n\t\t\t0.5\n\t\t],\n\t\t\"b\": 2\n\t}\n}"},
+	}
+	for _, tt := range tests {
+		b, err := MarshalProtoJSON(tt.opts, m)
+		if err != nil {
+			t.Errorf("%s: MarshalProtoJSON: %v", tt.name, err)
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("%s: MarshalProtoJSON = %s, want %s", tt.name, b, tt.want)
+		}
+	}
+
+	// messages without Dict fields are written like protojson does, up
+	// to whitespace
+	w := wrapperspb.Int64(-3)
+	b, err := MarshalProtoJSON(protojson.MarshalOptions{}, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := protojson.Marshal(w); string(b) != string(want) {
+		t.Errorf("MarshalProtoJSON(%v) = %s, want %s", w, b, want)
+	}
+}
+
+func TestUnmarshalProtoJSON(t *testing.T) {
+	types := testMessageTypes(t)
+	m := newTestMessage(t, types)
+	in := `{"i64":"9007199254740993","u64":18446744073709551615,"snake_name":"s","config":{"a":[0.5],"b":2,"c":18446744073709551616}}`
+	if err := UnmarshalProtoJSON(protojson.UnmarshalOptions{Resolver: types}, []byte(in), m); err != nil {
+		t.Fatal(err)
+	}
+	want := textMessage(t, types, `
+i64: 9007199254740993 u64: 18446744073709551615 snake_name: "s"
+config: {fields: {key: "a" value: {list_value: {values: [{float_value: 0.5}]}}} fields: {key: "b" value: {int_value: 2}} fields: {key: "c" value: {decimal_value: "18446744073709551616"}}}
+`)
+	if !proto.Equal(m, want) {
+		t.Errorf("UnmarshalProtoJSON(%s) = %v, want %v", in, m, want)
+	}
+
+	b, err := MarshalProtoJSON(protojson.MarshalOptions{Resolver: types}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := newTestMessage(t, types)
+	if err := UnmarshalProtoJSON(protojson.UnmarshalOptions{Resolver: types}, b, out); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(out, m) {
+		t.Errorf("round trip through %s = %v, want %v", b, out, m)
+	}
+}
+
+func TestUnmarshalProtoJSONErrors(t *testing.T) {
+	types := testMessageTypes(t)
+	o := protojson.UnmarshalOptions{Resolver: types}
+
+	err := UnmarshalProtoJSON(o, []byte(`{"i64":1,"i64":2}`), newTestMessage(t, types))
+	var de *DuplicateKeyError
+	if !errors.As(err, &de) {
+		t.Errorf("UnmarshalProtoJSON with a duplicate key = %v, want a *DuplicateKeyError", err)
+	}
+
+	in := []byte(`{"nope":1,"i32":2}`)
+	var me *MessageError
+	if err := UnmarshalProtoJSON(o, in, newTestMessage(t, types)); !errors.As(err, &me) || me.Path != "/nope" {
+		t.Errorf("UnmarshalProtoJSON with an unknown key = %v, want a *MessageError at /nope", err)
+	}
+	o.DiscardUnknown = true
+	if err := UnmarshalProtoJSON(o, in, newTestMessage(t, types)); err != nil {
+		t.Errorf("UnmarshalProtoJSON with DiscardUnknown: %v", err)
+	}
+
+	if err := UnmarshalProtoJSON(o, []byte(`{`), newTestMessage(t, types)); err == nil {
+		t.Error("UnmarshalProtoJSON of invalid JSON succeeded")
+	}
+}
@@ -0,0 +1,646 @@
+package structpb
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// DecodeOptions is a configurable decoder from Value to Go values.
+type DecodeOptions struct {
+	// DisallowUnknownFields reports an error when a Dict has a key that
+	// does not match any field of the destination struct.
+	DisallowUnknownFields bool
+
+	// WeaklyTypedInput allows scalar values to be converted between kinds:
+	// strings are parsed into numbers and booleans, numbers and booleans
+	// are formatted into strings, and booleans and numbers are
+	// interchangeable (true is 1).
+	WeaklyTypedInput bool
+}
+
+// A DecodeError describes a value that could not be decoded into a Go value.
+type DecodeError struct {
+	// Path is the JSON Pointer of the offending value, "" is the root.
+	Path string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "root"
+	}
+	return fmt.Sprintf("cannot decode %s: %v", path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// Decode populates out, which must be a non-nil pointer, from v.
+//
+// It is the inverse of NewValue: structs are populated by their `structpb`
+// or `json` tags (field names are matched case-insensitively if there is no
+// exact match), maps and slices are allocated as needed, and IntValue is
+// decoded into integers without passing through float64.
+//
+// All numbers, whatever their kind, are decoded into floats with the same
+// precision rule: an integer must be represented exactly by the float32 or
+// float64 destination, or Decode fails rather than round it, while a
+// number with a fractional part is rounded to the nearest float. Numbers
+// out of the float range fail too.
+//
+// Destinations implementing json.Unmarshaler receive v encoded as JSON, and
+// destinations implementing encoding.TextUnmarshaler receive string values.
+// An interface{} destination receives the same values as returned by
+// Value.AsInterface, except that non-finite floats stay float64.
+func Decode(v *Value, out interface{}) error {
+	return DecodeOptions{}.Decode(v, out)
+}
+
+// Decode populates out from x, see the package level Decode.
+func (x *Dict) Decode(out interface{}) error {
+	return DecodeOptions{}.Decode(NewStructValue(x), out)
+}
+
+// Decode populates out from x, see the package level Decode.
+func (x *List) Decode(out interface{}) error {
+	return DecodeOptions{}.Decode(NewListValue(x), out)
+}
+
+// Decode populates out, which must be a non-nil pointer, from v
+// using the options in o.
+func (o DecodeOptions) Decode(v *Value, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &DecodeError{Err: fmt.Errorf("non-pointer or nil destination %T", out)}
+	}
+	return o.decode("", v, rv.Elem())
+}
+
+func (o DecodeOptions) errorf(path string, format string, args ...interface{}) error {
+	return &DecodeError{Path: path, Err: fmt.Errorf(format, args...)}
+}
+
+func (o DecodeOptions) mismatch(path string, x *Value, t reflect.Type) error {
+	return o.errorf(path, "cannot convert %s to %s", kindName(x), t)
+}
+
+// indirect walks down v allocating pointers as needed, until it gets to a
+// non-pointer or to a value implementing one of the unmarshaler interfaces.
+// If decodingNull is true, it stops at the last pointer so it can be set to nil.
+func indirect(v reflect.Value, decodingNull bool) (json.Unmarshaler, encoding.TextUnmarshaler, reflect.Value) {
+	for {
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			e := v.Elem()
+			if e.Kind() == reflect.Ptr && !e.IsNil() && (!decodingNull || e.Elem().Kind() == reflect.Ptr) {
+				v = e
+				continue
+			}
+		}
+
+		if v.Kind() != reflect.Ptr {
+			if v.CanAddr() {
+				pv := v.Addr()
+				if pv.Type().Implements(jsonUnmarshalerType) || pv.Type().Implements(textUnmarshalerType) {
+					v = pv
+				}
+			}
+			if v.Kind() != reflect.Ptr {
+				break
+			}
+		}
+
+		if decodingNull && v.CanSet() && v.Elem().Kind() != reflect.Ptr {
+			break
+		}
+
+		switch v.Type() {
+		case valueType, dictType, listType:
+			return nil, nil, v
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		if v.Type().NumMethod() > 0 && v.CanInterface() {
+			if u, ok := v.Interface().(json.Unmarshaler); ok {
+				return u, nil, reflect.Value{}
+			}
+			if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+				return nil, u, v
+			}
+		}
+		v = v.Elem()
+	}
+	return nil, nil, v
+}
+
+func (o DecodeOptions) decode(path string, x *Value, v reflect.Value) error {
+	_, isNull := x.GetKind().(*Value_NullValue)
+	isNull = isNull || x.GetKind() == nil
+
+	ju, tu, v := indirect(v, isNull)
+	if ju != nil {
+		b, err := x.MarshalJSON()
+		if err != nil {
+			return &DecodeError{Path: path, Err: err}
+		}
+		if err := ju.UnmarshalJSON(b); err != nil {
+			return &DecodeError{Path: path, Err: err}
+		}
+		return nil
+	}
+	if tu != nil {
+		if s, ok := x.GetKind().(*Value_StringValue); ok {
+			if err := tu.UnmarshalText([]byte(s.StringValue)); err != nil {
+				return &DecodeError{Path: path, Err: err}
+			}
+			return nil
+		}
+		if isNull {
+			return nil
+		}
+		if v.Type() == reflect.PtrTo(bigFloatType) {
+			switch x.GetKind().(type) {
+			case *Value_IntValue, *Value_FloatValue, *Value_DecimalValue:
+				b, err := MarshalOptions{NonFinite: NonFiniteError}.Marshal(x)
+				if err == nil {
+					err = tu.UnmarshalText(b)
+				}
+				if err != nil {
+					return &DecodeError{Path: path, Err: err}
+				}
+				return nil
+			}
+		}
+		v = v.Elem()
+		if v.Kind() != reflect.Struct && v.Kind() != reflect.Map && v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return o.mismatch(path, x, v.Type())
+		}
+	}
+
+	switch v.Type() {
+	case valueType:
+		v.Set(reflect.ValueOf(x.Clone()))
+		return nil
+	case dictType:
+		switch k := x.GetKind().(type) {
+		case *Value_DictValue:
+			v.Set(reflect.ValueOf(k.DictValue.Clone()))
+		case *Value_NullValue, nil:
+			v.Set(reflect.Zero(v.Type()))
+		default:
+			return o.mismatch(path, x, v.Type())
+		}
+		return nil
+	case listType:
+		switch k := x.GetKind().(type) {
+		case *Value_ListValue:
+			v.Set(reflect.ValueOf(k.ListValue.Clone()))
+		case *Value_NullValue, nil:
+			v.Set(reflect.Zero(v.Type()))
+		default:
+			return o.mismatch(path, x, v.Type())
+		}
+		return nil
+	}
+
+	if isNull {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return o.mismatch(path, x, v.Type())
+		}
+		v.Set(reflect.ValueOf(x.toInterface()))
+		return nil
+	case reflect.Bool:
+		return o.decodeBool(path, x, v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return o.decodeInt(path, x, v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return o.decodeUint(path, x, v)
+	case reflect.Float32, reflect.Float64:
+		return o.decodeFloat(path, x, v)
+	case reflect.String:
+		return o.decodeString(path, x, v)
+	case reflect.Struct:
+		return o.decodeStruct(path, x, v)
+	case reflect.Map:
+		return o.decodeMap(path, x, v)
+	case reflect.Slice, reflect.Array:
+		return o.decodeList(path, x, v)
+	default:
+		return o.mismatch(path, x, v.Type())
+	}
+}
+
+func (o DecodeOptions) decodeBool(path string, x *Value, v reflect.Value) error {
+	switch k := x.GetKind().(type) {
+	case *Value_BoolValue:
+		v.SetBool(k.BoolValue)
+		return nil
+	case *Value_IntValue:
+		if o.WeaklyTypedInput {
+			v.SetBool(k.IntValue != 0)
+			return nil
+		}
+	case *Value_FloatValue:
+		if o.WeaklyTypedInput {
+			v.SetBool(k.FloatValue != 0)
+			return nil
+		}
+	case *Value_DecimalValue:
+		if r := numberRat(x); r != nil && o.WeaklyTypedInput {
+			v.SetBool(r.Sign() != 0)
+			return nil
+		}
+	case *Value_StringValue:
+		if o.WeaklyTypedInput {
+			b, err := strconv.ParseBool(k.StringValue)
+			if err != nil {
+				return o.errorf(path, "cannot parse %q as bool", k.StringValue)
+			}
+			v.SetBool(b)
+			return nil
+		}
+	}
+	return o.mismatch(path, x, v.Type())
+}
+
+func (o DecodeOptions) decodeInt(path string, x *Value, v reflect.Value) error {
+	var i int64
+	switch k := x.GetKind().(type) {
+	case *Value_IntValue:
+		i = k.IntValue
+	case *Value_FloatValue:
+		f := k.FloatValue
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return o.errorf(path, "cannot convert %v to %s without loss", f, v.Type())
+		}
+		i = int64(f)
+	case *Value_DecimalValue:
+		r := numberRat(x)
+		if r == nil || !r.IsInt() || !r.Num().IsInt64() {
+			return o.errorf(path, "cannot convert %s to %s without loss", k.DecimalValue, v.Type())
+		}
+		i = r.Num().Int64()
+	case *Value_DurationValue:
+		if v.Type() != durationType && !o.WeaklyTypedInput {
+			return o.mismatch(path, x, v.Type())
+		}
+		i = int64(k.DurationValue.AsDuration())
+	case *Value_BoolValue:
+		if !o.WeaklyTypedInput {
+			return o.mismatch(path, x, v.Type())
+		}
+		if k.BoolValue {
+			i = 1
+		}
+	case *Value_StringValue:
+		if !o.WeaklyTypedInput {
+			return o.mismatch(path, x, v.Type())
+		}
+		var err error
+		i, err = strconv.ParseInt(strings.TrimSpace(k.StringValue), 0, 64)
+		if err != nil {
+			return o.errorf(path, "cannot parse %q as %s", k.StringValue, v.Type())
+		}
+	default:
+		return o.mismatch(path, x, v.Type())
+	}
+	if v.OverflowInt(i) {
+		return o.errorf(path, "%d overflows %s", i, v.Type())
+	}
+	v.SetInt(i)
+	return nil
+}
+
+func (o DecodeOptions) decodeUint(path string, x *Value, v reflect.Value) error {
+	var u uint64
+	switch k := x.GetKind().(type) {
+	case *Value_IntValue:
+		if k.IntValue < 0 {
+			return o.errorf(path, "%d overflows %s", k.IntValue, v.Type())
+		}
+		u = uint64(k.IntValue)
+	case *Value_FloatValue:
+		f := k.FloatValue
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+			return o.errorf(path, "cannot convert %v to %s without loss", f, v.Type())
+		}
+		u = uint64(f)
+	case *Value_DecimalValue:
+		r := numberRat(x)
+		if r == nil || !r.IsInt() || !r.Num().IsUint64() {
+			return o.errorf(path, "cannot convert %s to %s without loss", k.DecimalValue, v.Type())
+		}
+		u = r.Num().Uint64()
+	case *Value_BoolValue:
+		if !o.WeaklyTypedInput {
+			return o.mismatch(path, x, v.Type())
+		}
+		if k.BoolValue {
+			u = 1
+		}
+	case *Value_StringValue:
+		if !o.WeaklyTypedInput {
+			return o.mismatch(path, x, v.Type())
+		}
+		var err error
+		u, err = strconv.ParseUint(strings.TrimSpace(k.StringValue), 0, 64)
+		if err != nil {
+			return o.errorf(path, "cannot parse %q as %s", k.StringValue, v.Type())
+		}
+	default:
+		return o.mismatch(path, x, v.Type())
+	}
+	if v.OverflowUint(u) {
+		return o.errorf(path, "%d overflows %s", u, v.Type())
+	}
+	v.SetUint(u)
+	return nil
+}
+
+// decodeFloat applies the precision rule documented on Decode to every
+// kind of number.
+func (o DecodeOptions) decodeFloat(path string, x *Value, v reflect.Value) error {
+	var r *big.Rat // the exact value, if finite
+	var f float64
+	var text string
+	switch k := x.GetKind().(type) {
+	case *Value_FloatValue:
+		f, text = k.FloatValue, strconv.FormatFloat(k.FloatValue, 'g', -1, 64)
+		if !math.IsNaN(f) && !math.IsInf(f, 0) {
+			r = new(big.Rat).SetFloat64(f)
+		}
+	case *Value_IntValue:
+		r, text = new(big.Rat).SetInt64(k.IntValue), strconv.FormatInt(k.IntValue, 10)
+	case *Value_DecimalValue:
+		text = k.DecimalValue
+		if r = numberRat(x); r == nil {
+			return o.errorf(path, "cannot parse %q as %s", k.DecimalValue, v.Type())
+		}
+	case *Value_BoolValue:
+		if !o.WeaklyTypedInput {
+			return o.mismatch(path, x, v.Type())
+		}
+		if k.BoolValue {
+			f = 1
+		}
+	case *Value_StringValue:
+		if !o.WeaklyTypedInput {
+			return o.mismatch(path, x, v.Type())
+		}
+		text = strings.TrimSpace(k.StringValue)
+		var ok bool
+		if isNumber(text) {
+			r, ok = parseRat(text)
+		} else {
+			var err error
+			f, err = strconv.ParseFloat(text, 64)
+			ok = err == nil
+			if ok && !math.IsNaN(f) && !math.IsInf(f, 0) {
+				r = new(big.Rat).SetFloat64(f)
+			}
+		}
+		if !ok {
+			return o.errorf(path, "cannot parse %q as %s", k.StringValue, v.Type())
+		}
+	default:
+		return o.mismatch(path, x, v.Type())
+	}
+	if r != nil {
+		var exact bool
+		if v.Kind() == reflect.Float32 {
+			var f32 float32
+			f32, exact = r.Float32()
+			f = float64(f32)
+		} else {
+			f, exact = r.Float64()
+		}
+		if math.IsInf(f, 0) {
+			return o.errorf(path, "%s overflows %s", text, v.Type())
+		}
+		if !exact && r.IsInt() {
+			return o.errorf(path, "cannot convert %s to %s without loss", text, v.Type())
+		}
+	}
+	v.SetFloat(f)
+	return nil
+}
+
+func (o DecodeOptions) decodeString(path string, x *Value, v reflect.Value) error {
+	if v.Type() == jsonNumberType {
+		switch x.GetKind().(type) {
+		case *Value_IntValue, *Value_FloatValue, *Value_DecimalValue:
+			b, err := MarshalOptions{NonFinite: NonFiniteError}.Marshal(x)
+			if err != nil {
+				return &DecodeError{Path: path, Err: err}
+			}
+			v.SetString(string(b))
+			return nil
+		}
+	}
+
+	switch k := x.GetKind().(type) {
+	case *Value_StringValue:
+		v.SetString(k.StringValue)
+		return nil
+	case *Value_IntValue:
+		if o.WeaklyTypedInput {
+			v.SetString(strconv.FormatInt(k.IntValue, 10))
+			return nil
+		}
+	case *Value_FloatValue:
+		if o.WeaklyTypedInput {
+			v.SetString(strconv.FormatFloat(k.FloatValue, 'g', -1, 64))
+			return nil
+		}
+	case *Value_DecimalValue:
+		if o.WeaklyTypedInput {
+			v.SetString(k.DecimalValue)
+			return nil
+		}
+	case *Value_BytesValue:
+		if o.WeaklyTypedInput {
+			v.SetString(base64.StdEncoding.EncodeToString(k.BytesValue))
+			return nil
+		}
+	case *Value_TimestampValue:
+		if o.WeaklyTypedInput {
+			v.SetString(formatTimestamp(k.TimestampValue))
+			return nil
+		}
+	case *Value_DurationValue:
+		if o.WeaklyTypedInput {
+			v.SetString(formatDuration(k.DurationValue))
+			return nil
+		}
+	case *Value_BoolValue:
+		if o.WeaklyTypedInput {
+			v.SetString(strconv.FormatBool(k.BoolValue))
+			return nil
+		}
+	}
+	return o.mismatch(path, x, v.Type())
+}
+
+func (o DecodeOptions) decodeStruct(path string, x *Value, v reflect.Value) error {
+	d, ok := x.GetKind().(*Value_DictValue)
+	if !ok {
+		return o.mismatch(path, x, v.Type())
+	}
+
+	fields := cachedTypeFields(v.Type())
+	for _, key := range d.DictValue.sortedKeys() {
+		fx := d.DictValue.Fields[key]
+		var f *structField
+		for i := range fields {
+			if fields[i].name == key {
+				f = &fields[i]
+				break
+			}
+		}
+		if f == nil {
+			for i := range fields {
+				if strings.EqualFold(fields[i].name, key) {
+					f = &fields[i]
+					break
+				}
+			}
+		}
+
+		fieldPath := appendPointer(path, key)
+		if f == nil {
+			if o.DisallowUnknownFields {
+				return o.errorf(fieldPath, "unknown field %q in %s", key, v.Type())
+			}
+			continue
+		}
+
+		fv, ok := fieldByIndex(v, f.index, true)
+		if !ok {
+			return o.errorf(fieldPath, "cannot set embedded pointer to unexported struct in %s", v.Type())
+		}
+		if err := o.decode(fieldPath, fx, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o DecodeOptions) decodeMap(path string, x *Value, v reflect.Value) error {
+	d, ok := x.GetKind().(*Value_DictValue)
+	if !ok {
+		return o.mismatch(path, x, v.Type())
+	}
+
+	t := v.Type()
+	kt := t.Key()
+	switch kt.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !reflect.PtrTo(kt).Implements(textUnmarshalerType) {
+			return o.mismatch(path, x, t)
+		}
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, len(d.DictValue.GetFields())))
+	}
+
+	for _, key := range d.DictValue.sortedKeys() {
+		fx := d.DictValue.Fields[key]
+		fieldPath := appendPointer(path, key)
+
+		ev := reflect.New(t.Elem()).Elem()
+		if err := o.decode(fieldPath, fx, ev); err != nil {
+			return err
+		}
+
+		kv := reflect.New(kt)
+		if tu, ok := kv.Interface().(encoding.TextUnmarshaler); ok && kt.Kind() != reflect.String {
+			if err := tu.UnmarshalText([]byte(key)); err != nil {
+				return &DecodeError{Path: fieldPath, Err: err}
+			}
+		} else {
+			switch kt.Kind() {
+			case reflect.String:
+				kv.Elem().SetString(key)
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				i, err := strconv.ParseInt(key, 10, 64)
+				if err != nil || kv.Elem().OverflowInt(i) {
+					return o.errorf(fieldPath, "cannot convert key %q to %s", key, kt)
+				}
+				kv.Elem().SetInt(i)
+			default:
+				u, err := strconv.ParseUint(key, 10, 64)
+				if err != nil || kv.Elem().OverflowUint(u) {
+					return o.errorf(fieldPath, "cannot convert key %q to %s", key, kt)
+				}
+				kv.Elem().SetUint(u)
+			}
+		}
+
+		v.SetMapIndex(kv.Elem(), ev)
+	}
+	return nil
+}
+
+func (o DecodeOptions) decodeList(path string, x *Value, v reflect.Value) error {
+	l, ok := x.GetKind().(*Value_ListValue)
+	if !ok {
+		if b, ok := x.GetKind().(*Value_BytesValue); ok && v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(append([]byte{}, b.BytesValue...))
+			return nil
+		}
+		if s, ok := x.GetKind().(*Value_StringValue); ok && v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := base64.StdEncoding.DecodeString(s.StringValue)
+			if err != nil {
+				return &DecodeError{Path: path, Err: err}
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		return o.mismatch(path, x, v.Type())
+	}
+
+	values := l.ListValue.GetValues()
+	if v.Kind() == reflect.Array {
+		if len(values) > v.Len() {
+			return o.errorf(path, "cannot fit %d elements into %s", len(values), v.Type())
+		}
+		for i := len(values); i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+	} else {
+		v.Set(reflect.MakeSlice(v.Type(), len(values), len(values)))
+	}
+
+	for i, ex := range values {
+		if err := o.decode(appendPointer(path, strconv.Itoa(i)), ex, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package structpb
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type decodeStruct struct {
+	Embedded
+	Name    string            `json:"name"`
+	Alias   string            `structpb:"alias"`
+	Skip    string            `json:"-"`
+	Count   int32             `json:"count"`
+	Ratio   float64           `json:"ratio"`
+	Ptr     *int              `json:"ptr"`
+	List    []string          `json:"list"`
+	Array   [2]int            `json:"array"`
+	Map     map[string]uint8  `json:"map"`
+	IntKeys map[int]bool      `json:"int_keys"`
+	Bytes   []byte            `json:"bytes"`
+	When    time.Time         `json:"when"`
+	Wait    time.Duration     `json:"wait"`
+	Any     interface{}       `json:"any"`
+	Nested  *decodeStruct     `json:"nested"`
+	Raw     map[string]*Value `json:"raw"`
+}
+
+func TestDecode(t *testing.T) {
+	x := mustParse(t, `{
+		"E": 1, "tagged": "t", "name": "n", "ALIAS": "a", "Skip": "s",
+		"count": 3, "ratio": 2, "ptr": 4, "list": ["x", "y"], "array": [5, 6],
+		"map": {"k": 7}, "int_keys": {"8": true}, "bytes": "aGk=",
+		"when": "2021-01-02T03:04:05Z", "wait": 1500000000,
+		"any": {"l": [1, 2.5, null]}, "nested": {"name": "inner"},
+		"raw": {"v": [true]}
+	}`)
+	var got decodeStruct
+	if err := Decode(x, &got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	four := 4
+	want := decodeStruct{
+		Embedded: Embedded{E: 1, Tagged: "t"},
+		Name:     "n",
+		Alias:    "a",
+		Count:    3,
+		Ratio:    2,
+		Ptr:      &four,
+		List:     []string{"x", "y"},
+		Array:    [2]int{5, 6},
+		Map:      map[string]uint8{"k": 7},
+		IntKeys:  map[int]bool{8: true},
+		Bytes:    []byte("hi"),
+		When:     time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		Wait:     1500 * time.Millisecond,
+		Any:      map[string]interface{}{"l": []interface{}{int64(1), 2.5, nil}},
+		Nested:   &decodeStruct{Name: "inner"},
+	}
+	raw := got.Raw
+	got.Raw = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode =\n%#v\nwant\n%#v", got, want)
+	}
+	if s := toJSON(t, raw["v"]); s != `[true]` {
+		t.Errorf("Decode into *Value = %s, want [true]", s)
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	in := decodeStruct{Name: "n", Count: -2, List: []string{}, Map: map[string]uint8{"a": 1}}
+	v, err := NewValue(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out decodeStruct
+	if err := Decode(v, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip = %#v, want %#v", out, in)
+	}
+}
+
+func TestDecodeNumbers(t *testing.T) {
+	tests := []struct {
+		name string
+		in   *Value
+		out  interface{}
+		want interface{}
+	}{
+		{"int64", NewIntValue(math.MaxInt64), new(int64), int64(math.MaxInt64)},
+		{"integral float to int", NewFloatValue(3), new(int), 3},
+		{"int to uint8", NewIntValue(255), new(uint8), uint8(255)},
+		{"int to float64", NewIntValue(1 << 53), new(float64), float64(1 << 53)},
+		{"int to float32", NewIntValue(1 << 24), new(float32), float32(1 << 24)},
+		{"float to float32", NewFloatValue(0.5), new(float32), float32(0.5)},
+		{"fraction rounded to float32", NewFloatValue(0.1), new(float32), float32(0.1)},
+		{"decimal fraction rounded", &Value{Kind: &Value_DecimalValue{DecimalValue: "0.1"}}, new(float32), float32(0.1)},
+		{"decimal integer", &Value{Kind: &Value_DecimalValue{DecimalValue: "1e20"}}, new(float64), 1e20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Decode(tt.in, tt.out); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got := reflect.ValueOf(tt.out).Elem().Interface(); got != tt.want {
+				t.Errorf("Decode = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  interface{}
+		path string
+	}{
+		{"fraction to int", `1.5`, new(int), ""},
+		{"overflow int8", `128`, new(int8), ""},
+		{"negative to uint", `-1`, new(uint), ""},
+		{"inexact float64", `9007199254740993`, new(float64), ""},
+		{"inexact float32", `16777217`, new(float32), ""},
+		{"inexact float to float32", `16777217.0`, new(float32), ""},
+		{"float32 overflow", `1e39`, new(float32), ""},
+		{"string to int", `"1"`, new(int), ""},
+		{"nested path", `{"list": ["a", 1]}`, new(decodeStruct), "/list/1"},
+		{"list into struct", `[]`, new(decodeStruct), ""},
+		{"too many elements", `{"array": [1, 2, 3]}`, new(decodeStruct), "/array"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Decode(mustParse(t, tt.in), tt.out)
+			var de *DecodeError
+			if !errors.As(err, &de) {
+				t.Fatalf("Decode error = %v, want a *DecodeError", err)
+			}
+			if de.Path != tt.path {
+				t.Errorf("DecodeError.Path = %q, want %q", de.Path, tt.path)
+			}
+		})
+	}
+
+	// a DecimalValue follows the same precision rule as other numbers
+	for _, d := range []string{"9007199254740993", "1e400"} {
+		var f float64
+		var de *DecodeError
+		if err := Decode(&Value{Kind: &Value_DecimalValue{DecimalValue: d}}, &f); !errors.As(err, &de) {
+			t.Errorf("Decode(%s) into a float64 = %v, %v, want a *DecodeError", d, f, err)
+		}
+	}
+}
+
+func TestDecodeOptions(t *testing.T) {
+	var s struct {
+		N int    `json:"n"`
+		B bool   `json:"b"`
+		S string `json:"s"`
+	}
+	x := mustParse(t, `{"n": " 12 ", "b": 1, "s": 2.5}`)
+	if err := Decode(x, &s); err == nil {
+		t.Error("Decode of mismatched kinds succeeded")
+	}
+	if err := (DecodeOptions{WeaklyTypedInput: true}).Decode(x, &s); err != nil {
+		t.Fatalf("Decode with WeaklyTypedInput: %v", err)
+	}
+	if s.N != 12 || !s.B || s.S != "2.5" {
+		t.Errorf("Decode with WeaklyTypedInput = %+v", s)
+	}
+
+	unknown := mustParse(t, `{"n": 1, "other": 2}`)
+	if err := Decode(unknown, &s); err != nil {
+		t.Errorf("Decode with an unknown field: %v", err)
+	}
+	err := DecodeOptions{DisallowUnknownFields: true}.Decode(unknown, &s)
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Path != "/other" {
+		t.Errorf("Decode with DisallowUnknownFields = %v, want a *DecodeError at /other", err)
+	}
+
+	if err := Decode(x, s); err == nil {
+		t.Error("Decode into a non-pointer succeeded")
+	}
+}
@@ -0,0 +1,321 @@
+package structpb
+
+import (
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/runtime/protoimpl"
+)
+
+var (
+	valueType         = reflect.TypeOf((*Value)(nil))
+	dictType          = reflect.TypeOf((*Dict)(nil))
+	listType          = reflect.TypeOf((*List)(nil))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	bigIntType        = reflect.TypeOf(big.Int{})
+	bigFloatType      = reflect.TypeOf(big.Float{})
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+)
+
+// NewDictFrom constructs a Dict from a Go struct or a map with string-like keys.
+// The value is converted using NewValue, and must result in a DictValue.
+func NewDictFrom(v interface{}) (*Dict, error) {
+	x, err := NewValue(v)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := x.GetKind().(*Value_DictValue)
+	if !ok {
+		return nil, protoimpl.X.NewError("cannot convert %T to Dict", v)
+	}
+	return d.DictValue, nil
+}
+
+// reflectEncoder walks arbitrary Go values and converts them to Value.
+type reflectEncoder struct {
+	opts EncodeOptions
+
+	// ptrSeen holds the pointers (and maps, slices) on the current path,
+	// used to report cycles instead of recursing forever
+	ptrSeen map[ptrKey]struct{}
+}
+
+type ptrKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+func (o EncodeOptions) newValueReflect(v reflect.Value) (*Value, error) {
+	e := &reflectEncoder{opts: o, ptrSeen: map[ptrKey]struct{}{}}
+	return e.encode(v)
+}
+
+func (e *reflectEncoder) enter(v reflect.Value) (func(), error) {
+	key := ptrKey{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	if _, ok := e.ptrSeen[key]; ok {
+		return nil, protoimpl.X.NewError("encountered a cycle via %s", v.Type())
+	}
+	e.ptrSeen[key] = struct{}{}
+	return func() { delete(e.ptrSeen, key) }, nil
+}
+
+func (e *reflectEncoder) encode(v reflect.Value) (*Value, error) {
+	if !v.IsValid() {
+		return NewNullValue(), nil
+	}
+
+	t := v.Type()
+
+	switch t {
+	case valueType:
+		if v.IsNil() {
+			return NewNullValue(), nil
+		}
+		return v.Interface().(*Value).Clone(), nil
+	case dictType:
+		if v.IsNil() {
+			return NewNullValue(), nil
+		}
+		return NewStructValue(v.Interface().(*Dict).Clone()), nil
+	case listType:
+		if v.IsNil() {
+			return NewNullValue(), nil
+		}
+		return NewListValue(v.Interface().(*List).Clone()), nil
+	case timeType:
+		return newTimestampValue(v.Interface().(time.Time))
+	case durationType:
+		return NewDurationValue(time.Duration(v.Int())), nil
+	case reflect.PtrTo(bigIntType), reflect.PtrTo(bigFloatType), reflect.PtrTo(timeType):
+		if v.IsNil() {
+			return NewNullValue(), nil
+		}
+		return e.encode(v.Elem())
+	case bigIntType:
+		i := v.Interface().(big.Int)
+		return e.opts.BigNumbers.bigIntValue(&i)
+	case bigFloatType:
+		f := v.Interface().(big.Float)
+		return e.opts.BigNumbers.bigFloatValue(&f)
+	case jsonNumberType:
+		s := v.String()
+		if s == "" {
+			// like encoding/json
+			s = "0"
+		}
+		if !isNumber(s) {
+			return nil, protoimpl.X.NewError("invalid number: %q", s)
+		}
+		return e.opts.BigNumbers.numberValue(s)
+	}
+
+	if t.Kind() != reflect.Ptr && v.CanAddr() {
+		pt := reflect.PtrTo(t)
+		if pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType) {
+			return e.encodeMarshaler(v.Addr())
+		}
+	}
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return e.encodeMarshaler(v)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return NewBoolValue(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewIntValue(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.opts.BigNumbers.uintValue(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return NewFloatValue(v.Float()), nil
+	case reflect.String:
+		s := v.String()
+		if !utf8.ValidString(s) {
+			return nil, protoimpl.X.NewError("invalid UTF-8 in string: %q", s)
+		}
+		return NewStringValue(s), nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return NewNullValue(), nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return NewNullValue(), nil
+		}
+		leave, err := e.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return e.encode(v.Elem())
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return NewNullValue(), nil
+		}
+		leave, err := e.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return e.encodeMap(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return NewNullValue(), nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && !reflect.PtrTo(t.Elem()).Implements(textMarshalerType) {
+			return NewBytesValue(append([]byte{}, v.Bytes()...)), nil
+		}
+		leave, err := e.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return e.encodeArray(v)
+	case reflect.Array:
+		return e.encodeArray(v)
+	default:
+		return nil, protoimpl.X.NewError("invalid type: %s", t)
+	}
+}
+
+func (e *reflectEncoder) encodeMarshaler(v reflect.Value) (*Value, error) {
+	// a nil pointer or interface, as in struct{ M json.Marshaler }{}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return NewNullValue(), nil
+	}
+
+	switch m := v.Interface().(type) {
+	case json.Marshaler:
+		b, err := m.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		x := &Value{}
+		if err := (UnmarshalOptions{BigNumbers: e.opts.BigNumbers}).Unmarshal(b, x); err != nil {
+			return nil, protoimpl.X.NewError("invalid JSON from %s.MarshalJSON: %v", v.Type(), err)
+		}
+		return x, nil
+	case encoding.TextMarshaler:
+		b, err := m.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		if !utf8.Valid(b) {
+			return nil, protoimpl.X.NewError("invalid UTF-8 in string: %q", b)
+		}
+		return NewStringValue(string(b)), nil
+	}
+
+	return nil, protoimpl.X.NewError("invalid type: %s", v.Type())
+}
+
+func (e *reflectEncoder) encodeStruct(v reflect.Value) (*Value, error) {
+	fields := cachedTypeFields(v.Type())
+	x := &Dict{Fields: make(map[string]*Value, len(fields))}
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok {
+			continue
+		}
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		if !utf8.ValidString(f.name) {
+			return nil, protoimpl.X.NewError("invalid UTF-8 in string: %q", f.name)
+		}
+		fx, err := e.encode(fv)
+		if err != nil {
+			return nil, err
+		}
+		x.Fields[f.name] = fx
+	}
+	return NewStructValue(x), nil
+}
+
+func (e *reflectEncoder) encodeMap(v reflect.Value) (*Value, error) {
+	x := &Dict{Fields: make(map[string]*Value, v.Len())}
+	iter := v.MapRange()
+	for iter.Next() {
+		k, err := mapKeyString(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		if !utf8.ValidString(k) {
+			return nil, protoimpl.X.NewError("invalid UTF-8 in string: %q", k)
+		}
+		fx, err := e.encode(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		x.Fields[k] = fx
+	}
+	return NewStructValue(x), nil
+}
+
+func (e *reflectEncoder) encodeArray(v reflect.Value) (*Value, error) {
+	x := &List{Values: make([]*Value, v.Len())}
+	for i := range x.Values {
+		ex, err := e.encode(v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		x.Values[i] = ex
+	}
+	return NewListValue(x), nil
+}
+
+// mapKeyString converts a map key to a Dict key, using the same rules as
+// encoding/json: string kinds are used directly, encoding.TextMarshaler
+// is honored, and integers are formatted in decimal.
+func mapKeyString(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", nil
+		}
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", protoimpl.X.NewError("unsupported map key type: %s", k.Type())
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
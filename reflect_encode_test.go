@@ -0,0 +1,213 @@
+package structpb
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type textKey struct{ a, b string }
+
+func (k textKey) MarshalText() ([]byte, error) { return []byte(k.a + "-" + k.b), nil }
+
+type jsonMarshaler struct{}
+
+func (jsonMarshaler) MarshalJSON() ([]byte, error) { return []byte(`{"from":"json"}`), nil }
+
+type Embedded struct {
+	E       int
+	Shadow  string
+	Tagged  string `json:"tagged"`
+	Promote string
+}
+
+type encodeStruct struct {
+	Embedded
+	Name     string            `json:"name"`
+	Alias    string            `structpb:"alias" json:"ignored"`
+	Skip     string            `json:"-"`
+	Empty    string            `json:"empty,omitempty"`
+	Zero     int               `json:",omitempty"`
+	Shadow   string            // shadows Embedded.Shadow
+	Ptr      *int              `json:"ptr"`
+	Slice    []int             `json:"slice"`
+	NilSlice []int             `json:"nil_slice"`
+	Map      map[int]string    `json:"map"`
+	Bytes    []byte            `json:"bytes"`
+	Nested   *encodeStruct     `json:"nested,omitempty"`
+	Keys     map[textKey]bool  `json:"keys"`
+	Custom   jsonMarshaler     `json:"custom"`
+	Any      interface{}       `json:"any"`
+	Strings  map[string]string `json:"strings,omitempty"`
+	private  int
+}
+
+func TestNewValueReflect(t *testing.T) {
+	one := 1
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{"nil", nil, `null`},
+		{"bool", true, `true`},
+		{"int8", int8(-3), `-3`},
+		{"uint16", uint16(7), `7`},
+		{"float32", float32(0.5), `0.5`},
+		{"string", "héllo", `"héllo"`},
+		{"bytes", []byte("hi"), `"aGk="`},
+		{"time", time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), `"2021-01-02T03:04:05Z"`},
+		{"duration", 1500 * time.Millisecond, `"1.500s"`},
+		{"big int", big.NewInt(42), `42`},
+		{"json number", json.Number("12"), `12`},
+		{"typed slice", []string{"a", "b"}, `["a","b"]`},
+		{"array", [2]int{1, 2}, `[1,2]`},
+		{"nil typed slice", []int(nil), `null`},
+		{"typed map", map[string]int{"b": 2, "a": 1}, `{"a":1,"b":2}`},
+		{"int keys", map[int]bool{2: true, 10: false}, `{"10":false,"2":true}`},
+		{"nil map", map[string]int(nil), `null`},
+		{"pointer", &one, `1`},
+		{"nil pointer", (*int)(nil), `null`},
+		{"marshaler", jsonMarshaler{}, `{"from":"json"}`},
+		{"nil marshaler", struct{ M json.Marshaler }{}, `{"M":null}`},
+		{"dash key", struct {
+			Dash int `json:"-,"`
+			Skip int `json:"-"`
+		}{1, 2}, `{"-":1}`},
+		{"struct", encodeStruct{
+			Embedded: Embedded{E: 1, Shadow: "lost", Tagged: "t", Promote: "p"},
+			Name:     "n",
+			Alias:    "a",
+			Skip:     "s",
+			Shadow:   "kept",
+			Ptr:      &one,
+			Slice:    []int{1},
+			Map:      map[int]string{1: "x"},
+			Bytes:    []byte{0xff},
+			Keys:     map[textKey]bool{{"x", "y"}: true},
+			Any:      []interface{}{1, "two"},
+			private:  1,
+		}, `{"E":1,"Promote":"p","Shadow":"kept","alias":"a","any":[1,"two"],"bytes":"/w==","custom":{"from":"json"},"keys":{"x-y":true},"map":{"1":"x"},"name":"n","nil_slice":null,"ptr":1,"slice":[1],"tagged":"t"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewValue(tt.in)
+			if err != nil {
+				t.Fatalf("NewValue: %v", err)
+			}
+			if got := toJSON(t, v); got != tt.want {
+				t.Errorf("NewValue = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+type tagTieA struct {
+	X int `json:",omitempty"`
+}
+
+type tagTieB struct {
+	X int
+}
+
+type tagWins struct {
+	tagTieB
+	tagNamed
+}
+
+type tagNamed struct {
+	Y int `json:"X"`
+}
+
+func TestNewValueFieldDominance(t *testing.T) {
+	// like encoding/json, a tag without a name does not make a field
+	// dominant: both X are untagged at the same depth and cancel out
+	type ambiguous struct {
+		tagTieA
+		tagTieB
+	}
+	for _, tt := range []struct {
+		name string
+		in   interface{}
+	}{
+		{"untagged tie", ambiguous{tagTieA{1}, tagTieB{2}}},
+		{"named tag wins", tagWins{tagTieB{1}, tagNamed{2}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := json.Marshal(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := NewValue(tt.in)
+			if err != nil {
+				t.Fatalf("NewValue: %v", err)
+			}
+			if got := toJSON(t, v); got != string(want) {
+				t.Errorf("NewValue = %s, encoding/json = %s", got, want)
+			}
+		})
+	}
+}
+
+func TestNewValueBigNumbers(t *testing.T) {
+	tests := []struct {
+		policy BigNumberPolicy
+		in     interface{}
+		want   string
+	}{
+		{BigNumberFloat, uint64(math.MaxUint64), `18446744073709552000`},
+		{BigNumberString, uint64(math.MaxUint64), `"18446744073709551615"`},
+		{BigNumberDecimal, uint64(math.MaxUint64), `18446744073709551615`},
+		{BigNumberString, new(big.Int).Lsh(big.NewInt(1), 70), `"1180591620717411303424"`},
+	}
+	for _, tt := range tests {
+		v, err := EncodeOptions{BigNumbers: tt.policy}.NewValue(tt.in)
+		if err != nil {
+			t.Errorf("NewValue(%v) with policy %d: %v", tt.in, tt.policy, err)
+			continue
+		}
+		if got := toJSON(t, v); got != tt.want {
+			t.Errorf("NewValue(%v) with policy %d = %s, want %s", tt.in, tt.policy, got, tt.want)
+		}
+	}
+	if _, err := (EncodeOptions{BigNumbers: BigNumberError}).NewValue(uint64(math.MaxUint64)); err == nil {
+		t.Error("NewValue(MaxUint64) with BigNumberError succeeded")
+	}
+}
+
+func TestNewValueErrors(t *testing.T) {
+	type cycle struct {
+		Next *cycle `json:"next"`
+	}
+	c := &cycle{}
+	c.Next = c
+	for name, in := range map[string]interface{}{
+		"channel":      make(chan int),
+		"func":         func() {},
+		"invalid utf8": "\xff",
+		"struct keys":  map[struct{ A int }]int{{1}: 1},
+		"cycle":        c,
+		"nan big":      new(big.Float).SetInf(false),
+	} {
+		if v, err := NewValue(in); err == nil {
+			t.Errorf("NewValue(%s) = %v, want an error", name, v)
+		}
+	}
+}
+
+func TestNewDictFrom(t *testing.T) {
+	d, err := NewDictFrom(struct {
+		A int `json:"a"`
+	}{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, d); got != `{"a":1}` {
+		t.Errorf("NewDictFrom = %s", got)
+	}
+	if _, err := NewDictFrom([]int{1}); err == nil {
+		t.Error("NewDictFrom([]int) succeeded")
+	}
+}
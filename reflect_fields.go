@@ -0,0 +1,185 @@
+package structpb
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// structField describes how a Go struct field maps to a Dict key.
+type structField struct {
+	name      string
+	tagged    bool
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]structField
+
+// cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
+func cachedTypeFields(t reflect.Type) []structField {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]structField)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.([]structField)
+}
+
+// parseTag returns the key name and options of a struct field, and whether
+// the field is skipped. As with encoding/json, only the tag "-" skips the
+// field, while "-," names its key "-".
+//
+// The `structpb` tag takes precedence over the `json` tag, so a type can
+// keep its JSON layout while using a different one for Dict.
+func parseTag(sf reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag, found := sf.Tag.Lookup("structpb")
+	if !found {
+		tag, found = sf.Tag.Lookup("json")
+	}
+	if !found {
+		return "", false, false
+	}
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty, false
+}
+
+// typeFields returns the fields that should be recognized for the given type,
+// following the same visibility rules as encoding/json: embedded structs are
+// flattened, and among fields with the same name the shallowest one wins,
+// with tagged fields preferred over untagged ones at the same depth.
+func typeFields(t reflect.Type) []structField {
+	type queued struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []structField
+	current := []queued{}
+	next := []queued{{typ: t}}
+	visited := map[reflect.Type]bool{}
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+
+		for _, q := range current {
+			if visited[q.typ] {
+				continue
+			}
+			visited[q.typ] = true
+
+			for i := 0; i < q.typ.NumField(); i++ {
+				sf := q.typ.Field(i)
+				name, omitEmpty, skip := parseTag(sf)
+				if skip {
+					continue
+				}
+				// like encoding/json, only a tag with a name counts, not
+				// one such as `json:",omitempty"`
+				tagged := name != ""
+
+				ft := sf.Type
+				if sf.Anonymous {
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					if sf.PkgPath != "" && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if sf.PkgPath != "" {
+					continue
+				}
+
+				index := make([]int, len(q.index)+1)
+				copy(index, q.index)
+				index[len(q.index)] = i
+
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, queued{typ: ft, index: index})
+					continue
+				}
+				if name == "" {
+					name = sf.Name
+				}
+
+				fields = append(fields, structField{
+					name:      name,
+					tagged:    tagged,
+					index:     index,
+					typ:       sf.Type,
+					omitEmpty: omitEmpty,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].name != fields[j].name {
+			return fields[i].name < fields[j].name
+		}
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+		return fields[i].tagged && !fields[j].tagged
+	})
+
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		group := fields[i:j]
+		// the first field is dominant unless another one at the same depth
+		// and with the same tagging is equally qualified
+		if len(group) == 1 ||
+			len(group[0].index) < len(group[1].index) ||
+			(group[0].tagged && !group[1].tagged) {
+			out = append(out, group[0])
+		}
+		i = j
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return lessIndex(out[i].index, out[j].index)
+	})
+
+	return out
+}
+
+func lessIndex(a, b []int) bool {
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// fieldByIndex returns the nested field of v at index, allocating nil
+// embedded pointers when alloc is true. ok is false if a nil embedded
+// pointer was found and alloc is false.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
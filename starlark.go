@@ -1,7 +1,14 @@
 package structpb
 
 import (
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	startime "go.starlark.net/lib/time"
 	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
 )
 
 func (x *NullValue) ToStarlark() starlark.NoneType {
@@ -29,8 +36,8 @@ func (x *Dict) ToStarlark() *starlark.Dict {
 
 	dict := starlark.NewDict(len(x.Fields))
 
-	for k, v := range x.Fields {
-		_ = dict.SetKey(starlark.String(k), v.ToStarlark())
+	for _, k := range x.Keys() {
+		_ = dict.SetKey(starlark.String(k), x.Fields[k].ToStarlark())
 	}
 
 	return dict
@@ -46,10 +53,21 @@ func (x *Value) ToStarlark() starlark.Value {
 		return starlark.MakeInt64(v.IntValue)
 	case *Value_FloatValue:
 		return starlark.Float(v.FloatValue)
+	case *Value_DecimalValue:
+		if r := numberRat(x); r != nil && r.IsInt() {
+			return starlark.MakeBigInt(r.Num())
+		}
+		return starlark.Float(numberFloat(x))
 	case *Value_StringValue:
 		return starlark.String(v.StringValue)
 	case *Value_BoolValue:
 		return starlark.Bool(v.BoolValue)
+	case *Value_BytesValue:
+		return starlark.Bytes(v.BytesValue)
+	case *Value_TimestampValue:
+		return startime.Time(v.TimestampValue.AsTime())
+	case *Value_DurationValue:
+		return startime.Duration(v.DurationValue.AsDuration())
 	case *Value_DictValue:
 		return v.DictValue.ToStarlark()
 	case *Value_ListValue:
@@ -58,3 +76,202 @@ func (x *Value) ToStarlark() starlark.Value {
 		return starlark.None
 	}
 }
+
+// FromStarlarkOptions configures the conversion of Starlark values to Value.
+type FromStarlarkOptions struct {
+	// BigNumbers selects how an Int outside of the int64 range is
+	// converted, see BigNumberPolicy.
+	BigNumbers BigNumberPolicy
+
+	// PreserveKeyOrder converts dicts into ordered Dicts, keeping their
+	// iteration order. Struct fields are always in lexical order.
+	PreserveKeyOrder bool
+}
+
+// A FromStarlarkError describes a Starlark value that could not be
+// converted to a Value.
+type FromStarlarkError struct {
+	// Path is the JSON Pointer of the offending value, "" is the root.
+	Path string
+	Err  error
+}
+
+func (e *FromStarlarkError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "root"
+	}
+	return fmt.Sprintf("cannot convert starlark value at %s: %v", path, e.Err)
+}
+
+func (e *FromStarlarkError) Unwrap() error { return e.Err }
+
+// FromStarlark converts a Starlark value to a Value, the inverse of
+// Value.ToStarlark:
+//
+//	╔═══════════════════════════╤═════════════════════════════════════════╗
+//	║ Starlark type             │ Conversion                              ║
+//	╠═══════════════════════════╪═════════════════════════════════════════╣
+//	║ None                      │ stored as NullValue                     ║
+//	║ bool                      │ stored as BoolValue                     ║
+//	║ int                       │ stored as IntValue, see BigNumberPolicy ║
+//	║ float                     │ stored as FloatValue                    ║
+//	║ string                    │ stored as StringValue                   ║
+//	║ bytes                     │ stored as BytesValue                    ║
+//	║ time.time, time.duration  │ stored as TimestampValue, DurationValue ║
+//	║ list, tuple, set          │ stored as ListValue                     ║
+//	║ dict                      │ stored as DictValue; keys must be str   ║
+//	║ struct                    │ stored as DictValue                     ║
+//	║ StarlarkDict, StarlarkList│ stored as a clone                       ║
+//	╚═══════════════════════════╧═════════════════════════════════════════╝
+//
+// Any other type, and a list or dict that contains itself, is reported
+// as a *FromStarlarkError.
+func FromStarlark(v starlark.Value) (*Value, error) {
+	return FromStarlarkOptions{}.FromStarlark(v)
+}
+
+// FromStarlark converts a Starlark value to a Value, see the package
+// level FromStarlark.
+func (o FromStarlarkOptions) FromStarlark(v starlark.Value) (*Value, error) {
+	c := &starlarkConverter{opts: o, seen: map[starlark.Value]struct{}{}}
+	return c.convert("", v)
+}
+
+type starlarkConverter struct {
+	opts FromStarlarkOptions
+	// seen holds the lists and dicts on the current path
+	seen map[starlark.Value]struct{}
+}
+
+func (c *starlarkConverter) errorf(path string, format string, args ...interface{}) error {
+	return &FromStarlarkError{Path: path, Err: fmt.Errorf(format, args...)}
+}
+
+func (c *starlarkConverter) enter(path string, v starlark.Value) (func(), error) {
+	if _, ok := c.seen[v]; ok {
+		return nil, c.errorf(path, "encountered a cycle via %s", v.Type())
+	}
+	c.seen[v] = struct{}{}
+	return func() { delete(c.seen, v) }, nil
+}
+
+func (c *starlarkConverter) convert(path string, v starlark.Value) (*Value, error) {
+	switch v := v.(type) {
+	case nil, starlark.NoneType:
+		return NewNullValue(), nil
+	case starlark.Bool:
+		return NewBoolValue(bool(v)), nil
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return NewIntValue(i), nil
+		}
+		x, err := c.opts.BigNumbers.bigIntValue(v.BigInt())
+		if err != nil {
+			return nil, &FromStarlarkError{Path: path, Err: err}
+		}
+		return x, nil
+	case starlark.Float:
+		return NewFloatValue(float64(v)), nil
+	case starlark.String:
+		if !utf8.ValidString(string(v)) {
+			return nil, c.errorf(path, "invalid UTF-8 in string: %q", string(v))
+		}
+		return NewStringValue(string(v)), nil
+	case starlark.Bytes:
+		return NewBytesValue([]byte(v)), nil
+	case startime.Time:
+		x, err := newTimestampValue(time.Time(v))
+		if err != nil {
+			return nil, &FromStarlarkError{Path: path, Err: err}
+		}
+		return x, nil
+	case startime.Duration:
+		return NewDurationValue(time.Duration(v)), nil
+	case *starlark.List:
+		leave, err := c.enter(path, v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return c.convertIterable(path, v, v.Len())
+	case starlark.Tuple:
+		return c.convertIterable(path, v, v.Len())
+	case *starlark.Set:
+		return c.convertIterable(path, v, v.Len())
+	case *starlark.Dict:
+		leave, err := c.enter(path, v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return c.convertDict(path, v)
+	case *starlarkstruct.Struct:
+		return c.convertStruct(path, v)
+	case *StarlarkDict:
+		if v.dict == nil {
+			return NewNullValue(), nil
+		}
+		return NewStructValue(v.dict.Clone()), nil
+	case *StarlarkList:
+		if v.list == nil {
+			return NewNullValue(), nil
+		}
+		return NewListValue(v.list.Clone()), nil
+	default:
+		return nil, c.errorf(path, "unsupported starlark type %s", v.Type())
+	}
+}
+
+func (c *starlarkConverter) convertIterable(path string, v starlark.Iterable, n int) (*Value, error) {
+	x := &List{Values: make([]*Value, 0, n)}
+	iter := v.Iterate()
+	defer iter.Done()
+	var e starlark.Value
+	for iter.Next(&e) {
+		ex, err := c.convert(appendPointer(path, strconv.Itoa(len(x.Values))), e)
+		if err != nil {
+			return nil, err
+		}
+		x.Values = append(x.Values, ex)
+	}
+	return NewListValue(x), nil
+}
+
+func (c *starlarkConverter) convertDict(path string, v *starlark.Dict) (*Value, error) {
+	x := &Dict{Fields: make(map[string]*Value, v.Len()), Ordered: c.opts.PreserveKeyOrder}
+	for _, item := range v.Items() {
+		k, ok := item[0].(starlark.String)
+		if !ok {
+			return nil, c.errorf(path, "dict key %s of type %s is not a string", item[0], item[0].Type())
+		}
+		fieldPath := appendPointer(path, string(k))
+		if !utf8.ValidString(string(k)) {
+			return nil, c.errorf(fieldPath, "invalid UTF-8 in dict key: %q", string(k))
+		}
+		fx, err := c.convert(fieldPath, item[1])
+		if err != nil {
+			return nil, err
+		}
+		x.Set(string(k), fx)
+	}
+	return NewStructValue(x), nil
+}
+
+func (c *starlarkConverter) convertStruct(path string, v *starlarkstruct.Struct) (*Value, error) {
+	names := v.AttrNames()
+	x := &Dict{Fields: make(map[string]*Value, len(names)), Ordered: c.opts.PreserveKeyOrder}
+	for _, name := range names {
+		fieldPath := appendPointer(path, name)
+		f, err := v.Attr(name)
+		if err != nil {
+			return nil, &FromStarlarkError{Path: fieldPath, Err: err}
+		}
+		fx, err := c.convert(fieldPath, f)
+		if err != nil {
+			return nil, err
+		}
+		x.Set(name, fx)
+	}
+	return NewStructValue(x), nil
+}
@@ -0,0 +1,365 @@
+package structpb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// StarlarkModule is a Starlark module exposing the operations of this
+// package with the same semantics as the Go API. Values are converted
+// with FromStarlark and results with Value.ToStarlark. It is predeclared
+// in scripts executed by a Runner, and can be made available to other
+// scripts with:
+//
+//	predeclared := starlark.StringDict{"structpb": structpb.StarlarkModule}
+//
+// The module members are:
+//
+//	get_path(value, pointer, default=None)
+//	    the value referenced by the JSON Pointer, or default, see Value.At
+//	set_path(value, pointer, v)
+//	    a copy of value with the referenced value set to v, see Value.SetAt
+//	merge(dst, src, lists="replace", merge_key="", nulls="overwrite")
+//	    the deep merge of src into dst, see MergeOptions; lists is one of
+//	    "replace", "append" or "merge_by_key", and nulls one of
+//	    "overwrite", "delete" or "ignore"
+//	merge_patch(target, patch)
+//	    the JSON Merge Patch of target, see MergePatch
+//	diff(a, b)
+//	    the list of changes from a to b, as dicts with the keys path, kind,
+//	    old and new, see Diff
+//	equal(a, b, numeric=False)
+//	    whether a and b are equal, with NumericEquivalence if numeric
+//	compare(a, b)
+//	    -1, 0 or +1, see Compare
+//	to_json(value, indent="", preserve_number_kind=False)
+//	    value encoded in JSON format, see MarshalOptions
+//	from_json(s)
+//	    the value decoded from JSON, see Value.UnmarshalJSON
+//	float(x)
+//	    x as a float, stored as FloatValue; x may be a number or a string
+//	    such as "1.5" or "NaN"
+//	int(x)
+//	    x as an int, stored as IntValue; x may be an int, an integral
+//	    float, or a string of decimal digits
+var StarlarkModule = &starlarkstruct.Module{
+	Name: "structpb",
+	Members: starlark.StringDict{
+		"get_path":    starlark.NewBuiltin("structpb.get_path", starlarkGetPath),
+		"set_path":    starlark.NewBuiltin("structpb.set_path", starlarkSetPath),
+		"merge":       starlark.NewBuiltin("structpb.merge", starlarkMerge),
+		"merge_patch": starlark.NewBuiltin("structpb.merge_patch", starlarkMergePatch),
+		"diff":        starlark.NewBuiltin("structpb.diff", starlarkDiff),
+		"equal":       starlark.NewBuiltin("structpb.equal", starlarkEqual),
+		"compare":     starlark.NewBuiltin("structpb.compare", starlarkCompare),
+		"to_json":     starlark.NewBuiltin("structpb.to_json", starlarkToJSON),
+		"from_json":   starlark.NewBuiltin("structpb.from_json", starlarkFromJSON),
+		"float":       starlark.NewBuiltin("structpb.float", starlarkFloat),
+		"int":         starlark.NewBuiltin("structpb.int", starlarkInt),
+	},
+}
+
+// fromStarlarkArg converts an argument of the builtin b without copying
+// the Dict or List of a StarlarkDict or StarlarkList, so the result must
+// not be modified, see copyStarlarkArg.
+func fromStarlarkArg(b *starlark.Builtin, name string, v starlark.Value) (*Value, error) {
+	switch v := v.(type) {
+	case *StarlarkDict:
+		if v.dict != nil {
+			return NewStructValue(v.dict), nil
+		}
+	case *StarlarkList:
+		if v.list != nil {
+			return NewListValue(v.list), nil
+		}
+	}
+	return copyStarlarkArg(b, name, v)
+}
+
+// copyStarlarkArg converts an argument of the builtin b to a Value it
+// can modify.
+func copyStarlarkArg(b *starlark.Builtin, name string, v starlark.Value) (*Value, error) {
+	x, err := FromStarlark(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: for parameter %s: %v", b.Name(), name, err)
+	}
+	return x, nil
+}
+
+// starlarkGetPath implements get_path(value, pointer, default=None), which
+// returns the value referenced by the JSON Pointer, or default if there is
+// none.
+func starlarkGetPath(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var value, dflt starlark.Value = nil, starlark.None
+	var pointer string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "value", &value, "pointer", &pointer, "default?", &dflt); err != nil {
+		return nil, err
+	}
+	x, err := fromStarlarkArg(b, "value", value)
+	if err != nil {
+		return nil, err
+	}
+	v, err := x.At(pointer)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrIndexOutOfRange) {
+			return dflt, nil
+		}
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	return v.ToStarlark(), nil
+}
+
+// starlarkSetPath implements set_path(value, pointer, v), which returns a
+// copy of value where the value referenced by the JSON Pointer is set to v,
+// see Value.SetAt.
+func starlarkSetPath(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var value, v starlark.Value
+	var pointer string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "value", &value, "pointer", &pointer, "v", &v); err != nil {
+		return nil, err
+	}
+	x, err := copyStarlarkArg(b, "value", value)
+	if err != nil {
+		return nil, err
+	}
+	y, err := fromStarlarkArg(b, "v", v)
+	if err != nil {
+		return nil, err
+	}
+	if err := x.SetAt(pointer, y); err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	return x.ToStarlark(), nil
+}
+
+// starlarkMerge implements merge(dst, src, lists="replace", merge_key="",
+// nulls="overwrite"), which returns the deep merge of src into dst.
+func starlarkMerge(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var dst, src starlark.Value
+	lists, nulls := "replace", "overwrite"
+	var o MergeOptions
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "dst", &dst, "src", &src,
+		"lists?", &lists, "merge_key?", &o.MergeKey, "nulls?", &nulls); err != nil {
+		return nil, err
+	}
+	switch lists {
+	case "replace":
+		o.Lists = ListReplace
+	case "append":
+		o.Lists = ListAppend
+	case "merge_by_key":
+		o.Lists = ListMergeByKey
+	default:
+		return nil, fmt.Errorf("%s: invalid lists strategy %q", b.Name(), lists)
+	}
+	switch nulls {
+	case "overwrite":
+		o.Nulls = NullOverwrite
+	case "delete":
+		o.Nulls = NullDelete
+	case "ignore":
+		o.Nulls = NullIgnore
+	default:
+		return nil, fmt.Errorf("%s: invalid nulls strategy %q", b.Name(), nulls)
+	}
+
+	x, err := fromStarlarkArg(b, "dst", dst)
+	if err != nil {
+		return nil, err
+	}
+	y, err := fromStarlarkArg(b, "src", src)
+	if err != nil {
+		return nil, err
+	}
+	v, err := o.DeepMerge(x, y)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	return v.ToStarlark(), nil
+}
+
+// starlarkMergePatch implements merge_patch(target, patch), which returns
+// the JSON Merge Patch of target.
+func starlarkMergePatch(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var target, patch starlark.Value
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "target", &target, "patch", &patch); err != nil {
+		return nil, err
+	}
+	x, err := fromStarlarkArg(b, "target", target)
+	if err != nil {
+		return nil, err
+	}
+	y, err := fromStarlarkArg(b, "patch", patch)
+	if err != nil {
+		return nil, err
+	}
+	return MergePatch(x, y).ToStarlark(), nil
+}
+
+// starlarkDiff implements diff(a, b), which returns the changes from a to b.
+func starlarkDiff(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var av, bv starlark.Value
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "a", &av, "b", &bv); err != nil {
+		return nil, err
+	}
+	x, err := fromStarlarkArg(b, "a", av)
+	if err != nil {
+		return nil, err
+	}
+	y, err := fromStarlarkArg(b, "b", bv)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := Diff(x, y)
+	elems := make([]starlark.Value, len(changes))
+	for i, c := range changes {
+		d := starlark.NewDict(4)
+		_ = d.SetKey(starlark.String("path"), starlark.String(c.Path))
+		_ = d.SetKey(starlark.String("kind"), starlark.String(c.Kind.String()))
+		_ = d.SetKey(starlark.String("old"), c.Old.ToStarlark())
+		_ = d.SetKey(starlark.String("new"), c.New.ToStarlark())
+		elems[i] = d
+	}
+	return starlark.NewList(elems), nil
+}
+
+// starlarkEqual implements equal(a, b, numeric=False).
+func starlarkEqual(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var av, bv starlark.Value
+	var numeric bool
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "a", &av, "b", &bv, "numeric?", &numeric); err != nil {
+		return nil, err
+	}
+	x, err := fromStarlarkArg(b, "a", av)
+	if err != nil {
+		return nil, err
+	}
+	y, err := fromStarlarkArg(b, "b", bv)
+	if err != nil {
+		return nil, err
+	}
+	if numeric {
+		return starlark.Bool(Equal(x, y, NumericEquivalence)), nil
+	}
+	return starlark.Bool(Equal(x, y)), nil
+}
+
+// starlarkCompare implements compare(a, b).
+func starlarkCompare(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var av, bv starlark.Value
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "a", &av, "b", &bv); err != nil {
+		return nil, err
+	}
+	x, err := fromStarlarkArg(b, "a", av)
+	if err != nil {
+		return nil, err
+	}
+	y, err := fromStarlarkArg(b, "b", bv)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.MakeInt(Compare(x, y)), nil
+}
+
+// starlarkToJSON implements to_json(value, indent="",
+// preserve_number_kind=False), which returns value encoded in JSON format.
+func starlarkToJSON(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var value starlark.Value
+	var o MarshalOptions
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "value", &value,
+		"indent?", &o.Indent, "preserve_number_kind?", &o.PreserveNumberKind); err != nil {
+		return nil, err
+	}
+	x, err := fromStarlarkArg(b, "value", value)
+	if err != nil {
+		return nil, err
+	}
+	p, err := o.Marshal(x)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	return starlark.String(p), nil
+}
+
+// starlarkFromJSON implements from_json(s),
+// which returns the value decoded from the JSON string s.
+func starlarkFromJSON(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var s string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "s", &s); err != nil {
+		return nil, err
+	}
+	x := &Value{}
+	if err := x.UnmarshalJSON([]byte(s)); err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	return x.ToStarlark(), nil
+}
+
+// starlarkFloat implements float(x), which converts a number, or a string
+// in JSON number syntax or naming a non-finite float, to a float.
+func starlarkFloat(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var x starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &x); err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case starlark.Float:
+		return x, nil
+	case starlark.Int:
+		f, _ := new(big.Float).SetInt(x.BigInt()).Float64()
+		if math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%s: int too large to convert to float", b.Name())
+		}
+		return starlark.Float(f), nil
+	case starlark.String:
+		if f, ok := parseNonFinite(string(x)); ok {
+			return starlark.Float(f), nil
+		}
+		if !isNumber(string(x)) {
+			return nil, fmt.Errorf("%s: invalid number %s", b.Name(), x)
+		}
+		f, err := strconv.ParseFloat(string(x), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: number %s overflows float64", b.Name(), x)
+		}
+		return starlark.Float(f), nil
+	default:
+		return nil, fmt.Errorf("%s: cannot convert %s to float", b.Name(), x.Type())
+	}
+}
+
+// starlarkInt implements int(x), which converts an int, an integral float
+// or a string of decimal digits to an int, without loss.
+func starlarkInt(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var x starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &x); err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case starlark.Int:
+		return x, nil
+	case starlark.Float:
+		f := float64(x)
+		if math.IsNaN(f) || math.IsInf(f, 0) || f != math.Trunc(f) {
+			return nil, fmt.Errorf("%s: cannot convert %v to int without loss", b.Name(), x)
+		}
+		i, _ := big.NewFloat(f).Int(nil)
+		return starlark.MakeBigInt(i), nil
+	case starlark.String:
+		s := string(x)
+		if !isNumber(s) || !isIntegralNumber([]byte(s)) {
+			return nil, fmt.Errorf("%s: invalid integer %s", b.Name(), x)
+		}
+		i, _ := new(big.Int).SetString(s, 10)
+		return starlark.MakeBigInt(i), nil
+	default:
+		return nil, fmt.Errorf("%s: cannot convert %s to int", b.Name(), x.Type())
+	}
+}
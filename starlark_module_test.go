@@ -0,0 +1,101 @@
+package structpb
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+// evalModule evaluates expr with StarlarkModule and cfg, a read-only
+// wrapper of the document doc, as globals.
+func evalModule(t *testing.T, doc, expr string) (starlark.Value, error) {
+	t.Helper()
+	return starlark.Eval(&starlark.Thread{}, "test.star", expr, starlark.StringDict{
+		"structpb": StarlarkModule,
+		"cfg":      NewStarlarkValue(mustParse(t, doc), false),
+	})
+}
+
+func TestStarlarkModule(t *testing.T) {
+	const doc = `{"a":{"b":[1,2]},"n":1}`
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`structpb.get_path(cfg, "/a/b/1")`, `2`},
+		{`structpb.get_path(cfg, "/a/c", "none")`, `"none"`},
+		{`structpb.get_path(cfg, "/a/b/5")`, `None`},
+		{`structpb.set_path(cfg, "/a/c", cfg.a.b)`, `{"a": {"b": [1, 2], "c": [1, 2]}, "n": 1}`},
+		{`structpb.merge(cfg, {"a": {"b": [3]}, "n": None}, lists="append", nulls="delete")`, `{"a": {"b": [1, 2, 3]}}`},
+		{`structpb.merge([{"k": 1, "v": 1}], [{"k": 1, "v": 2}], lists="merge_by_key", merge_key="k")`, `[{"k": 1, "v": 2}]`},
+		{`structpb.merge_patch(cfg, {"a": None, "m": 2})`, `{"m": 2, "n": 1}`},
+		{`structpb.diff(cfg, {"a": {"b": [1, 2]}, "n": 2})`, `[{"path": "/n", "kind": "modified", "old": 1, "new": 2}]`},
+		{`structpb.equal(cfg.n, 1.0)`, `False`},
+		{`structpb.equal(cfg.n, 1.0, numeric=True)`, `True`},
+		{`structpb.compare(cfg.n, 2)`, `-1`},
+		{`structpb.to_json(cfg)`, `"{\"a\":{\"b\":[1,2]},\"n\":1}"`},
+		{`structpb.to_json(structpb.float(2), preserve_number_kind=True)`, `"2.0"`},
+		{`structpb.from_json('{"x": [1.5, null]}')`, `{"x": [1.5, None]}`},
+		{`str(structpb.float("-Infinity"))`, `"-inf"`},
+		{`structpb.float(1 << 64)`, `1.8446744073709552e+19`},
+		{`structpb.int(2.0)`, `2`},
+		{`structpb.int("18446744073709551616")`, `18446744073709551616`},
+	}
+	for _, tt := range tests {
+		v, err := evalModule(t, doc, tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := v.String(); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestStarlarkModuleDoesNotModify(t *testing.T) {
+	const doc = `{"a":{"b":1}}`
+	x := mustParse(t, doc)
+	_, err := starlark.Eval(&starlark.Thread{}, "test.star", `[
+	structpb.set_path(cfg, "/a/b", 2),
+	structpb.merge(cfg, {"a": {"c": 3}}),
+	structpb.merge_patch(cfg, {"a": None}),
+]`, starlark.StringDict{"structpb": StarlarkModule, "cfg": NewStarlarkValue(x, false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, x); got != doc {
+		t.Errorf("module calls modified their argument: %s", got)
+	}
+}
+
+func TestStarlarkModuleErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		msg  string
+	}{
+		{`structpb.get_path(cfg, "a")`, "structpb.get_path"},
+		{`structpb.set_path([1], "/5", 1)`, "index out of range"},
+		{`structpb.merge(cfg, cfg, lists="zip")`, `invalid lists strategy "zip"`},
+		{`structpb.merge(cfg, cfg, nulls="keep")`, `invalid nulls strategy "keep"`},
+		{`structpb.to_json(len)`, "for parameter value"},
+		{`structpb.from_json("{")`, "structpb.from_json"},
+		{`structpb.float("1e999")`, "overflows float64"},
+		{`structpb.float("0x10")`, "invalid number"},
+		{`structpb.float((1 << 511) * (1 << 511) * (1 << 511))`, "int too large to convert to float"},
+		{`structpb.int(1.5)`, "without loss"},
+		{`structpb.int("1.0")`, "invalid integer"},
+		{`structpb.int(None)`, "cannot convert NoneType to int"},
+	}
+	for _, tt := range tests {
+		_, err := evalModule(t, `{"a":1}`, tt.expr)
+		if err == nil {
+			t.Errorf("%s succeeded", tt.expr)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: error %q, want it to contain %q", tt.expr, err, tt.msg)
+		}
+	}
+}
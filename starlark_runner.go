@@ -0,0 +1,194 @@
+package structpb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// ErrStepLimit is reported when a script exceeds Runner.MaxSteps.
+var ErrStepLimit = errors.New("step limit exceeded")
+
+// A Runner executes Starlark scripts which transform a Dict into a Value.
+//
+// Scripts are sandboxed: they cannot load modules, and only see the
+// following globals, plus the Predeclared ones:
+//
+//	input     the input Dict, wrapped read-only without copying it,
+//	          see NewStarlarkValue
+//	struct    the constructor of starlarkstruct.Struct
+//	structpb  StarlarkModule
+//
+// The zero Runner has no limits.
+type Runner struct {
+	// MaxSteps, if not zero, limits the number of execution steps.
+	MaxSteps uint64
+
+	// Timeout, if not zero, limits the execution time.
+	Timeout time.Duration
+
+	// Predeclared holds additional globals, which take precedence over
+	// the default ones.
+	Predeclared starlark.StringDict
+
+	// Print, if not nil, receives the output of the print builtin as it
+	// is produced. The output is always captured in RunResult.Output.
+	Print func(msg string)
+}
+
+// A RunResult holds the outcome of a script.
+type RunResult struct {
+	// Value is the result of the script.
+	Value *Value
+
+	// Output is the output of the print builtin, one line per call.
+	Output string
+}
+
+// A ScriptError describes the failure of a script.
+type ScriptError struct {
+	// Pos is the position in the script where the error occurred,
+	// it is not valid if unknown.
+	Pos syntax.Position
+
+	// Msg describes the error, without the position.
+	Msg string
+
+	// Backtrace is the Starlark call stack of an execution error.
+	Backtrace string
+
+	// Err is the cause of the error: ErrStepLimit, the context error for
+	// cancellations and timeouts, or the error reported by Starlark.
+	Err error
+}
+
+func (e *ScriptError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	}
+	return e.Msg
+}
+
+func (e *ScriptError) Unwrap() error { return e.Err }
+
+// Exec executes the Starlark file src with input bound as a global, and
+// returns the value of the global "result" set by the script.
+//
+// The returned RunResult is never nil, so the output printed before an
+// error is available. Errors are reported as a *ScriptError.
+func (r *Runner) Exec(ctx context.Context, filename, src string, input *Dict) (*RunResult, error) {
+	return r.run(ctx, filename, input, func(thread *starlark.Thread, predeclared starlark.StringDict) (starlark.Value, error) {
+		globals, err := starlark.ExecFile(thread, filename, src, predeclared)
+		if err != nil {
+			return nil, err
+		}
+		result, ok := globals["result"]
+		if !ok {
+			return nil, &ScriptError{Msg: "script did not set result"}
+		}
+		return result, nil
+	})
+}
+
+// Eval evaluates the Starlark expression expr with input bound as a
+// global, and returns its value, see Exec.
+func (r *Runner) Eval(ctx context.Context, expr string, input *Dict) (*RunResult, error) {
+	return r.run(ctx, "<expr>", input, func(thread *starlark.Thread, predeclared starlark.StringDict) (starlark.Value, error) {
+		return starlark.Eval(thread, "<expr>", expr, predeclared)
+	})
+}
+
+type runFunc func(thread *starlark.Thread, predeclared starlark.StringDict) (starlark.Value, error)
+
+func (r *Runner) run(ctx context.Context, name string, input *Dict, f runFunc) (*RunResult, error) {
+	var output strings.Builder
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			output.WriteString(msg)
+			output.WriteByte('\n')
+			if r.Print != nil {
+				r.Print(msg)
+			}
+		},
+	}
+	if r.MaxSteps > 0 {
+		thread.SetMaxExecutionSteps(r.MaxSteps)
+	}
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	predeclared := starlark.StringDict{
+		"input":    NewStarlarkDict(input, false),
+		"struct":   starlark.NewBuiltin("struct", starlarkstruct.Make),
+		"structpb": StarlarkModule,
+	}
+	for k, v := range r.Predeclared {
+		predeclared[k] = v
+	}
+
+	result := &RunResult{}
+	v, err := f(thread, predeclared)
+	if err == nil {
+		result.Value, err = FromStarlark(v)
+	}
+	result.Output = output.String()
+	if err != nil {
+		return result, r.scriptError(ctx, thread, err)
+	}
+	return result, nil
+}
+
+func (r *Runner) scriptError(ctx context.Context, thread *starlark.Thread, err error) error {
+	var se *ScriptError
+	if errors.As(err, &se) {
+		return se
+	}
+
+	e := &ScriptError{Msg: err.Error(), Err: err}
+	var syntaxErr syntax.Error
+	var resolveErrs resolve.ErrorList
+	var evalErr *starlark.EvalError
+	switch {
+	case errors.As(err, &syntaxErr):
+		e.Pos, e.Msg = syntaxErr.Pos, syntaxErr.Msg
+	case errors.As(err, &resolveErrs) && len(resolveErrs) > 0:
+		e.Pos, e.Msg = resolveErrs[0].Pos, resolveErrs[0].Msg
+	case errors.As(err, &evalErr):
+		e.Msg, e.Backtrace = evalErr.Msg, evalErr.Backtrace()
+		for i := 0; i < len(evalErr.CallStack); i++ {
+			if pos := evalErr.CallStack.At(i).Pos; pos.IsValid() && pos.Line > 0 {
+				e.Pos = pos
+				break
+			}
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		e.Err = ctx.Err()
+	case r.MaxSteps > 0 && thread.ExecutionSteps() >= r.MaxSteps:
+		e.Err = ErrStepLimit
+	}
+	return e
+}
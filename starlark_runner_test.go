@@ -0,0 +1,105 @@
+package structpb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+func TestRunnerExec(t *testing.T) {
+	input := mustParse(t, `{"spec":{"replicas":3},"name":"web"}`).GetDictValue()
+	var printed []string
+	r := &Runner{
+		Predeclared: starlark.StringDict{"factor": starlark.MakeInt(2)},
+		Print:       func(msg string) { printed = append(printed, msg) },
+	}
+	res, err := r.Exec(context.Background(), "transform.star", `
+print("scaling", input.name)
+result = structpb.set_path(input, "/spec/replicas", input.spec.replicas * factor)
+print("done")
+`, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, res.Value); got != `{"name":"web","spec":{"replicas":6}}` {
+		t.Errorf("Exec result = %s", got)
+	}
+	if res.Output != "scaling web\ndone\n" {
+		t.Errorf("Exec output = %q", res.Output)
+	}
+	if strings.Join(printed, "|") != "scaling web|done" {
+		t.Errorf("Print received %q", printed)
+	}
+	if got := toJSON(t, input); got != `{"name":"web","spec":{"replicas":3}}` {
+		t.Errorf("Exec modified the input: %s", got)
+	}
+}
+
+func TestRunnerEval(t *testing.T) {
+	input := mustParse(t, `{"a":[1,2,3]}`).GetDictValue()
+	res, err := (&Runner{}).Eval(context.Background(), `struct(n=len(input.a), first=input.a[0])`, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, res.Value); got != `{"first":1,"n":3}` {
+		t.Errorf("Eval result = %s", got)
+	}
+}
+
+func TestRunnerErrors(t *testing.T) {
+	input := mustParse(t, `{"a":1}`).GetDictValue()
+	tests := []struct {
+		name   string
+		runner Runner
+		src    string
+		line   int32
+		msg    string
+		err    error
+	}{
+		{"syntax", Runner{}, "x = 1\nresult = (", 2, "got end of file", nil},
+		{"resolve", Runner{}, "result = undefined", 1, "undefined: undefined", nil},
+		{"execution", Runner{}, "x = 1\nresult = input.a + \"s\"", 2, "unknown binary op", nil},
+		{"no result", Runner{}, "x = 1", 0, "script did not set result", nil},
+		{"load", Runner{}, `load("m.star", "x")`, 1, "load not implemented", nil},
+		{"write input", Runner{}, "input.a = 2", 1, "read-only", nil},
+		{"unconvertible result", Runner{}, "result = len", 0, "unsupported starlark type", nil},
+		{"step limit", Runner{MaxSteps: 1000}, "result = [x for x in range(100000)]", 0, "", ErrStepLimit},
+		{"timeout", Runner{Timeout: 10 * time.Millisecond}, "def f():\n  for x in range(1 << 40):\n    pass\nresult = f()", 0, "", context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		res, err := tt.runner.Exec(context.Background(), "test.star", tt.src, input)
+		var se *ScriptError
+		if !errors.As(err, &se) {
+			t.Errorf("%s: Exec error = %v, want a *ScriptError", tt.name, err)
+			continue
+		}
+		if res == nil {
+			t.Errorf("%s: Exec returned a nil RunResult", tt.name)
+		}
+		if tt.line != 0 && se.Pos.Line != tt.line {
+			t.Errorf("%s: ScriptError.Pos = %s, want line %d", tt.name, se.Pos, tt.line)
+		}
+		if !strings.Contains(se.Msg, tt.msg) {
+			t.Errorf("%s: ScriptError.Msg = %q, want it to contain %q", tt.name, se.Msg, tt.msg)
+		}
+		if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: Exec error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestRunnerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := (&Runner{}).Exec(ctx, "test.star", "print(\"before\")\ndef f():\n  for x in range(1 << 40):\n    pass\nresult = f()", &Dict{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Exec with a canceled context = %v, want context.Canceled", err)
+	}
+	if res.Output != "" && res.Output != "before\n" {
+		t.Errorf("Exec output = %q", res.Output)
+	}
+}
@@ -0,0 +1,241 @@
+package structpb
+
+import (
+	"errors"
+	"testing"
+
+	startime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// evalStarlark evaluates the Starlark expression expr, which can use the
+// struct and time modules.
+func evalStarlark(t *testing.T, expr string) starlark.Value {
+	t.Helper()
+	v, err := starlark.Eval(&starlark.Thread{}, "test.star", expr, starlark.StringDict{
+		"struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+		"time":   startime.Module,
+	})
+	if err != nil {
+		t.Fatalf("eval %s: %v", expr, err)
+	}
+	return v
+}
+
+func TestFromStarlark(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+		kind string
+	}{
+		{`None`, `null`, "NullValue"},
+		{`True`, `true`, "BoolValue"},
+		{`-3`, `-3`, "IntValue"},
+		{`1 << 62`, `4611686018427387904`, "IntValue"},
+		{`1 << 64`, `18446744073709552000`, "FloatValue"},
+		{`2.0`, `2`, "FloatValue"},
+		{`"héllo"`, `"héllo"`, "StringValue"},
+		{`b"\x00\xff"`, `"AP8="`, "BytesValue"},
+		{`time.time(year=2021, month=1, day=2)`, `"2021-01-02T00:00:00Z"`, "TimestampValue"},
+		{`time.parse_duration("1m30s")`, `"90s"`, "DurationValue"},
+		{`[1, "a", None]`, `[1,"a",null]`, "ListValue"},
+		{`(1, 2)`, `[1,2]`, "ListValue"},
+		{`{"b": [], "a": {}}`, `{"a":{},"b":[]}`, "DictValue"},
+		{`struct(z=1, a=struct(b=True))`, `{"a":{"b":true},"z":1}`, "DictValue"},
+	}
+	for _, tt := range tests {
+		x, err := FromStarlark(evalStarlark(t, tt.expr))
+		if err != nil {
+			t.Errorf("FromStarlark(%s): %v", tt.expr, err)
+			continue
+		}
+		if got := toJSON(t, x); got != tt.want || kindName(x) != tt.kind {
+			t.Errorf("FromStarlark(%s) = %s %s, want %s %s", tt.expr, kindName(x), got, tt.kind, tt.want)
+		}
+	}
+
+	set := starlark.NewSet(1)
+	_ = set.Insert(starlark.MakeInt(3))
+	if x, err := FromStarlark(set); err != nil || toJSON(t, x) != `[3]` {
+		t.Errorf("FromStarlark(set([3])) = %v, %v", x, err)
+	}
+}
+
+func TestFromStarlarkOptions(t *testing.T) {
+	x, err := FromStarlarkOptions{BigNumbers: BigNumberDecimal}.FromStarlark(evalStarlark(t, `1 << 64`))
+	if err != nil || kindName(x) != "DecimalValue" || toJSON(t, x) != `18446744073709551616` {
+		t.Errorf("FromStarlark(1 << 64) with BigNumberDecimal = %v, %v", x, err)
+	}
+	if _, err := (FromStarlarkOptions{BigNumbers: BigNumberError}).FromStarlark(evalStarlark(t, `[1 << 64]`)); err == nil {
+		t.Error("FromStarlark(1 << 64) with BigNumberError succeeded")
+	}
+
+	x, err = FromStarlarkOptions{PreserveKeyOrder: true}.FromStarlark(evalStarlark(t, `{"z": 1, "a": {"y": 2, "b": 3}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, x); got != `{"z":1,"a":{"y":2,"b":3}}` {
+		t.Errorf("FromStarlark with PreserveKeyOrder = %s", got)
+	}
+}
+
+func TestFromStarlarkErrors(t *testing.T) {
+	cycle := starlark.NewList(nil)
+	_ = cycle.Append(cycle)
+	tests := []struct {
+		name string
+		in   starlark.Value
+		path string
+	}{
+		{"non-string key", evalStarlark(t, `{"a": {1: 2}}`), "/a"},
+		{"unsupported type", evalStarlark(t, `[1, len]`), "/1"},
+		{"invalid utf-8", starlark.String("\xff"), ""},
+		{"cycle", cycle, "/0"},
+	}
+	for _, tt := range tests {
+		_, err := FromStarlark(tt.in)
+		var fe *FromStarlarkError
+		if !errors.As(err, &fe) {
+			t.Errorf("%s: FromStarlark error = %v, want a *FromStarlarkError", tt.name, err)
+			continue
+		}
+		if fe.Path != tt.path {
+			t.Errorf("%s: FromStarlarkError.Path = %q, want %q", tt.name, fe.Path, tt.path)
+		}
+	}
+
+	// the same list twice is not a cycle
+	shared := starlark.NewList([]starlark.Value{starlark.MakeInt(1)})
+	if _, err := FromStarlark(starlark.NewList([]starlark.Value{shared, shared})); err != nil {
+		t.Errorf("FromStarlark of a shared list: %v", err)
+	}
+}
+
+func TestStarlarkRoundTrip(t *testing.T) {
+	in := mustParse(t, `{"a":[1,2.5,"s",true,null],"b":{"c":{}}}`)
+	out, err := FromStarlark(in.ToStarlark())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(in, out) {
+		t.Errorf("round trip = %s, want %s", toJSON(t, out), toJSON(t, in))
+	}
+}
+
+// execStarlark runs src with cfg bound as a global and returns its globals.
+func execStarlark(cfg starlark.Value, src string) (starlark.StringDict, error) {
+	return starlark.ExecFile(&starlark.Thread{}, "test.star", src, starlark.StringDict{"cfg": cfg})
+}
+
+func TestStarlarkDictRead(t *testing.T) {
+	x := mustParse(t, `{"spec":{"replicas":3,"get":"shadowed"},"items":[1,[2,3]],"name":"n"}`)
+	globals, err := execStarlark(NewStarlarkValue(x, false), `
+replicas = cfg.spec.replicas
+name = cfg["name"]
+missing = cfg.get("missing", 7)
+shadowed = cfg.spec.get
+keys = [k for k in cfg]
+nested = cfg["items"][1][-1]
+sliced = cfg["items"][::-1]
+n = len(cfg)
+has = "name" in cfg
+same = cfg == cfg
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"replicas": `3`,
+		"name":     `"n"`,
+		"missing":  `7`,
+		"shadowed": `"shadowed"`,
+		"keys":     `["items", "name", "spec"]`,
+		"nested":   `3`,
+		"sliced":   `[[2, 3], 1]`,
+		"n":        `3`,
+		"has":      `True`,
+		"same":     `True`,
+	}
+	for name, w := range want {
+		if got := globals[name].String(); got != w {
+			t.Errorf("%s = %s, want %s", name, got, w)
+		}
+	}
+	if _, ok := globals["sliced"].(*starlark.List); !ok {
+		t.Errorf("slice of a structpb.List is a %s, want list", globals["sliced"].Type())
+	}
+}
+
+func TestStarlarkDictWrite(t *testing.T) {
+	x := mustParse(t, `{"spec":{"replicas":3},"items":[1,2]}`)
+	if _, err := execStarlark(NewStarlarkValue(x, true), `
+cfg.spec.replicas = 5
+cfg["name"] = "n"
+cfg["items"][0] = {"a": None}
+`); err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, x); got != `{"items":[{"a":null},2],"name":"n","spec":{"replicas":5}}` {
+		t.Errorf("after write-through = %s", got)
+	}
+
+	tests := []struct {
+		writable bool
+		src      string
+	}{
+		{false, `cfg.spec.replicas = 5`},
+		{false, `cfg["items"][0] = 1`},
+		{true, `cfg["x"] = len`},
+		{true, `cfg[1] = 1`},
+		{true, `cfg.spec.replicas + cfg.spec`},
+	}
+	for _, tt := range tests {
+		before := toJSON(t, x)
+		if _, err := execStarlark(NewStarlarkValue(x, tt.writable), tt.src); err == nil {
+			t.Errorf("%s (writable %v) succeeded", tt.src, tt.writable)
+		}
+		if after := toJSON(t, x); after != before {
+			t.Errorf("%s (writable %v) modified the value: %s", tt.src, tt.writable, after)
+		}
+	}
+}
+
+func TestStarlarkDictFreeze(t *testing.T) {
+	d := mustParse(t, `{"a":{"b":1}}`).GetDictValue()
+	w := NewStarlarkDict(d, true)
+	inner, _, err := w.Get(starlark.String("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Freeze()
+	// freezing the root also freezes the wrappers already handed out
+	if err := inner.(*StarlarkDict).SetKey(starlark.String("b"), starlark.MakeInt(2)); err == nil {
+		t.Error("SetKey on a frozen structpb.Dict succeeded")
+	}
+	if _, err := w.Hash(); err == nil {
+		t.Error("hashing a structpb.Dict succeeded")
+	}
+
+	out, err := FromStarlark(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.GetDictValue() == d || !Equal(out, NewStructValue(d)) {
+		t.Errorf("FromStarlark(wrapper) = %s, want a copy of the wrapped Dict", toJSON(t, out))
+	}
+}
+
+func TestStarlarkDictOrdered(t *testing.T) {
+	x := &Value{}
+	if err := (UnmarshalOptions{PreserveKeyOrder: true}).Unmarshal([]byte(`{"z":1,"a":2}`), x); err != nil {
+		t.Fatal(err)
+	}
+	globals, err := execStarlark(NewStarlarkValue(x, false), `keys = list(cfg)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := globals["keys"].String(); got != `["z", "a"]` {
+		t.Errorf("keys of an ordered Dict = %s", got)
+	}
+}
@@ -0,0 +1,325 @@
+package structpb
+
+import (
+	"fmt"
+	"sort"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// starlarkState is shared by all the wrappers of a tree, so freezing or
+// mutating it through any of them is seen by all.
+type starlarkState struct {
+	writable bool
+	frozen   bool
+}
+
+func (s *starlarkState) checkMutable(typ string) error {
+	if !s.writable {
+		return fmt.Errorf("cannot modify read-only %s", typ)
+	}
+	if s.frozen {
+		return fmt.Errorf("cannot modify frozen %s", typ)
+	}
+	return nil
+}
+
+// NewStarlarkValue returns x as a Starlark value without copying it:
+// Dicts and Lists are wrapped in a StarlarkDict or a StarlarkList, whose
+// elements are themselves wrapped when accessed, and scalars are converted
+// as by Value.ToStarlark.
+//
+// If writable is true, scripts may assign keys, attributes and elements,
+// which are written through to x until the value is frozen.
+func NewStarlarkValue(x *Value, writable bool) starlark.Value {
+	return newStarlarkValue(x, &starlarkState{writable: writable})
+}
+
+func newStarlarkValue(x *Value, state *starlarkState) starlark.Value {
+	switch v := x.GetKind().(type) {
+	case *Value_DictValue:
+		return &StarlarkDict{dict: v.DictValue, state: state}
+	case *Value_ListValue:
+		return &StarlarkList{list: v.ListValue, state: state}
+	default:
+		return x.ToStarlark()
+	}
+}
+
+// StarlarkDict is a Starlark value wrapping a *Dict without copying it.
+//
+// Scripts read fields by key, cfg["name"], or by attribute, cfg.name, and
+// iterate over the keys in the order of Dict.Keys. The methods get, keys,
+// values and items are also available, unless shadowed by a key of the
+// same name.
+type StarlarkDict struct {
+	dict  *Dict
+	state *starlarkState
+}
+
+var (
+	_ starlark.HasAttrs        = (*StarlarkDict)(nil)
+	_ starlark.HasSetField     = (*StarlarkDict)(nil)
+	_ starlark.Mapping         = (*StarlarkDict)(nil)
+	_ starlark.HasSetKey       = (*StarlarkDict)(nil)
+	_ starlark.IterableMapping = (*StarlarkDict)(nil)
+	_ starlark.Sequence        = (*StarlarkDict)(nil)
+	_ starlark.Comparable      = (*StarlarkDict)(nil)
+)
+
+// NewStarlarkDict wraps x, see NewStarlarkValue.
+func NewStarlarkDict(x *Dict, writable bool) *StarlarkDict {
+	return &StarlarkDict{dict: x, state: &starlarkState{writable: writable}}
+}
+
+// Dict returns the wrapped Dict.
+func (d *StarlarkDict) Dict() *Dict { return d.dict }
+
+func (d *StarlarkDict) String() string       { return d.dict.ToStarlark().String() }
+func (d *StarlarkDict) Type() string         { return "structpb.Dict" }
+func (d *StarlarkDict) Freeze()              { d.state.frozen = true }
+func (d *StarlarkDict) Truth() starlark.Bool { return d.Len() > 0 }
+func (d *StarlarkDict) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: %s", d.Type())
+}
+func (d *StarlarkDict) Len() int { return len(d.dict.GetFields()) }
+
+func (d *StarlarkDict) Get(k starlark.Value) (v starlark.Value, found bool, err error) {
+	key, ok := k.(starlark.String)
+	if !ok {
+		return nil, false, fmt.Errorf("%s key must be a string, got %s", d.Type(), k.Type())
+	}
+	x, found := d.dict.GetFields()[string(key)]
+	if !found {
+		return nil, false, nil
+	}
+	return newStarlarkValue(x, d.state), true, nil
+}
+
+func (d *StarlarkDict) SetKey(k, v starlark.Value) error {
+	key, ok := k.(starlark.String)
+	if !ok {
+		return fmt.Errorf("%s key must be a string, got %s", d.Type(), k.Type())
+	}
+	return d.set(string(key), v)
+}
+
+func (d *StarlarkDict) SetField(name string, v starlark.Value) error {
+	return d.set(name, v)
+}
+
+func (d *StarlarkDict) set(key string, v starlark.Value) error {
+	if err := d.state.checkMutable(d.Type()); err != nil {
+		return err
+	}
+	x, err := FromStarlark(v)
+	if err != nil {
+		return err
+	}
+	if !d.dict.Set(key, x) {
+		return fmt.Errorf("cannot modify nil %s", d.Type())
+	}
+	return nil
+}
+
+func (d *StarlarkDict) Attr(name string) (starlark.Value, error) {
+	if x, ok := d.dict.GetFields()[name]; ok {
+		return newStarlarkValue(x, d.state), nil
+	}
+	if m, ok := starlarkDictMethods[name]; ok {
+		return starlark.NewBuiltin(name, m).BindReceiver(d), nil
+	}
+	return nil, nil
+}
+
+func (d *StarlarkDict) AttrNames() []string {
+	names := d.dict.sortedKeys()
+	for name := range starlarkDictMethods {
+		if _, ok := d.dict.GetFields()[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *StarlarkDict) Iterate() starlark.Iterator {
+	return &starlarkKeyIterator{keys: d.dict.Keys()}
+}
+
+func (d *StarlarkDict) Items() []starlark.Tuple {
+	keys := d.dict.Keys()
+	items := make([]starlark.Tuple, len(keys))
+	for i, k := range keys {
+		items[i] = starlark.Tuple{starlark.String(k), newStarlarkValue(d.dict.Fields[k], d.state)}
+	}
+	return items
+}
+
+func (d *StarlarkDict) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	return compareStarlarkWrappers(op, NewStructValue(d.dict), NewStructValue(y.(*StarlarkDict).dict), d.Type())
+}
+
+var starlarkDictMethods = map[string]func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error){
+	"get": func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var key, dflt starlark.Value = nil, starlark.None
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &key, &dflt); err != nil {
+			return nil, err
+		}
+		v, found, err := b.Receiver().(*StarlarkDict).Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", b.Name(), err)
+		}
+		if !found {
+			return dflt, nil
+		}
+		return v, nil
+	},
+	"keys": func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+			return nil, err
+		}
+		keys := b.Receiver().(*StarlarkDict).dict.Keys()
+		elems := make([]starlark.Value, len(keys))
+		for i, k := range keys {
+			elems[i] = starlark.String(k)
+		}
+		return starlark.NewList(elems), nil
+	},
+	"values": func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+			return nil, err
+		}
+		items := b.Receiver().(*StarlarkDict).Items()
+		elems := make([]starlark.Value, len(items))
+		for i, item := range items {
+			elems[i] = item[1]
+		}
+		return starlark.NewList(elems), nil
+	},
+	"items": func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+			return nil, err
+		}
+		items := b.Receiver().(*StarlarkDict).Items()
+		elems := make([]starlark.Value, len(items))
+		for i, item := range items {
+			elems[i] = item
+		}
+		return starlark.NewList(elems), nil
+	},
+}
+
+// StarlarkList is a Starlark value wrapping a *List without copying it.
+//
+// Scripts index, slice and iterate over it like a Starlark list.
+type StarlarkList struct {
+	list  *List
+	state *starlarkState
+}
+
+var (
+	_ starlark.Indexable   = (*StarlarkList)(nil)
+	_ starlark.HasSetIndex = (*StarlarkList)(nil)
+	_ starlark.Sequence    = (*StarlarkList)(nil)
+	_ starlark.Sliceable   = (*StarlarkList)(nil)
+	_ starlark.Comparable  = (*StarlarkList)(nil)
+)
+
+// NewStarlarkList wraps x, see NewStarlarkValue.
+func NewStarlarkList(x *List, writable bool) *StarlarkList {
+	return &StarlarkList{list: x, state: &starlarkState{writable: writable}}
+}
+
+// List returns the wrapped List.
+func (l *StarlarkList) List() *List { return l.list }
+
+func (l *StarlarkList) String() string       { return l.list.ToStarlark().String() }
+func (l *StarlarkList) Type() string         { return "structpb.List" }
+func (l *StarlarkList) Freeze()              { l.state.frozen = true }
+func (l *StarlarkList) Truth() starlark.Bool { return l.Len() > 0 }
+func (l *StarlarkList) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: %s", l.Type())
+}
+func (l *StarlarkList) Len() int { return len(l.list.GetValues()) }
+
+func (l *StarlarkList) Index(i int) starlark.Value {
+	return newStarlarkValue(l.list.Values[i], l.state)
+}
+
+func (l *StarlarkList) SetIndex(i int, v starlark.Value) error {
+	if err := l.state.checkMutable(l.Type()); err != nil {
+		return err
+	}
+	x, err := FromStarlark(v)
+	if err != nil {
+		return err
+	}
+	l.list.Values[i] = x
+	return nil
+}
+
+func (l *StarlarkList) Slice(start, end, step int) starlark.Value {
+	var elems []starlark.Value
+	if step > 0 {
+		for i := start; i < end; i += step {
+			elems = append(elems, l.Index(i))
+		}
+	} else {
+		for i := start; i > end; i += step {
+			elems = append(elems, l.Index(i))
+		}
+	}
+	return starlark.NewList(elems)
+}
+
+func (l *StarlarkList) Iterate() starlark.Iterator {
+	return &starlarkListIterator{l: l}
+}
+
+func (l *StarlarkList) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	return compareStarlarkWrappers(op, NewListValue(l.list), NewListValue(y.(*StarlarkList).list), l.Type())
+}
+
+func compareStarlarkWrappers(op syntax.Token, x, y *Value, typ string) (bool, error) {
+	switch op {
+	case syntax.EQL:
+		return Equal(x, y, NumericEquivalence), nil
+	case syntax.NEQ:
+		return !Equal(x, y, NumericEquivalence), nil
+	default:
+		return false, fmt.Errorf("%s %s %s not implemented", typ, op, typ)
+	}
+}
+
+type starlarkKeyIterator struct {
+	keys []string
+}
+
+func (it *starlarkKeyIterator) Next(p *starlark.Value) bool {
+	if len(it.keys) == 0 {
+		return false
+	}
+	*p = starlark.String(it.keys[0])
+	it.keys = it.keys[1:]
+	return true
+}
+
+func (it *starlarkKeyIterator) Done() {}
+
+type starlarkListIterator struct {
+	l *StarlarkList
+	i int
+}
+
+func (it *starlarkListIterator) Next(p *starlark.Value) bool {
+	if it.i >= it.l.Len() {
+		return false
+	}
+	*p = it.l.Index(it.i)
+	it.i++
+	return true
+}
+
+func (it *starlarkListIterator) Done() {}
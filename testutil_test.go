@@ -0,0 +1,28 @@
+package structpb
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// mustParse decodes the JSON document s, failing the test on error.
+func mustParse(t testing.TB, s string) *Value {
+	t.Helper()
+	x := &Value{}
+	if err := x.UnmarshalJSON([]byte(s)); err != nil {
+		t.Fatalf("UnmarshalJSON(%s): %v", s, err)
+	}
+	return x
+}
+
+// toJSON encodes m with the default MarshalOptions, failing the test on
+// error. Unordered Dicts have sorted keys, so the result can be compared.
+func toJSON(t testing.TB, m proto.Message) string {
+	t.Helper()
+	b, err := MarshalOptions{}.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal(%v): %v", m, err)
+	}
+	return string(b)
+}
@@ -1,10 +1,16 @@
 package structpb
 
 import (
-	"encoding/base64"
+	"encoding/json"
 	"google.golang.org/protobuf/runtime/protoimpl"
 	"math"
+	"math/big"
+	"reflect"
+	"time"
 	"unicode/utf8"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // NewValue constructs a Value from a general-purpose Go interface.
@@ -16,17 +22,56 @@ import (
 //	║ bool                   │ stored as BoolValue                        ║
 //	║ int, int*              │ stored as IntValue                         ║
 //	║ uint*                  │ stored as IntValue                         ║
-//	║ uint, uint64           │ stored as IntValue / FloatValue            ║
+//	║ uint, uint64           │ stored as IntValue, see below if too large ║
 //	║ float32, float64       │ stored as FloatValue                       ║
+//	║ *big.Int, *big.Float   │ stored as IntValue / FloatValue, see below ║
+//	║ json.Number            │ stored as IntValue / FloatValue, see below ║
 //	║ string                 │ stored as StringValue; must be valid UTF-8 ║
-//	║ []byte                 │ stored as StringValue; base64-encoded      ║
+//	║ []byte                 │ stored as BytesValue                       ║
+//	║ time.Time              │ stored as TimestampValue                   ║
+//	║ time.Duration          │ stored as DurationValue                    ║
 //	║ map[string]interface{} │ stored as StructValue                      ║
 //	║ []interface{}          │ stored as ListValue                        ║
+//	║ *Value, *Dict, *List   │ stored as a clone                          ║
 //	╚════════════════════════╧════════════════════════════════════════════╝
 //
-// When converting an int64 or uint64 to a NumberValue, numeric precision loss
-// is possible since they are stored as a float64.
+// Any other type is converted by reflection, following the same rules as
+// encoding/json:
+//
+//	╔════════════════════════╤════════════════════════════════════════════╗
+//	║ Go type                │ Conversion                                 ║
+//	╠════════════════════════╪════════════════════════════════════════════╣
+//	║ json.Marshaler         │ output is decoded with Value.UnmarshalJSON ║
+//	║ encoding.TextMarshaler │ stored as StringValue                      ║
+//	║ pointer, interface     │ nil as NullValue, otherwise the element    ║
+//	║ struct                 │ stored as StructValue, see below           ║
+//	║ map                    │ stored as StructValue; nil as NullValue    ║
+//	║ slice, array           │ stored as ListValue; nil as NullValue      ║
+//	╚════════════════════════╧════════════════════════════════════════════╝
+//
+// Struct fields are named by their `structpb` tag, or by their `json` tag
+// if there is none, and support the "-" name and the "omitempty" option.
+// Fields of embedded structs are promoted into the parent Dict.
+// Map keys must be strings, integers or implement encoding.TextMarshaler.
+//
+// Numbers that cannot be stored exactly as an IntValue or a FloatValue,
+// such as a uint64 above math.MaxInt64, are stored as a FloatValue, so
+// precision loss is possible. Use EncodeOptions to select another
+// BigNumberPolicy.
 func NewValue(v interface{}) (*Value, error) {
+	return EncodeOptions{}.NewValue(v)
+}
+
+// EncodeOptions configures the conversion of Go values to Value.
+type EncodeOptions struct {
+	// BigNumbers selects how numbers that cannot be stored exactly as an
+	// IntValue or a FloatValue are converted, see BigNumberPolicy.
+	BigNumbers BigNumberPolicy
+}
+
+// NewValue constructs a Value from a general-purpose Go interface,
+// see the package level NewValue.
+func (o EncodeOptions) NewValue(v interface{}) (*Value, error) {
 	switch v := v.(type) {
 	case nil:
 		return NewNullValue(), nil
@@ -49,43 +94,82 @@ func NewValue(v interface{}) (*Value, error) {
 	case uint32:
 		return NewIntValue(int64(v)), nil
 	case uint:
-		if v > math.MaxInt64 {
-			return NewFloatValue(float64(v)), nil
-		} else {
-			return NewIntValue(int64(v)), nil
-		}
+		return o.BigNumbers.uintValue(uint64(v))
 	case uint64:
-		if v > math.MaxInt64 {
-			return NewFloatValue(float64(v)), nil
-		} else {
-			return NewIntValue(int64(v)), nil
-		}
+		return o.BigNumbers.uintValue(v)
 	case float32:
 		return NewFloatValue(float64(v)), nil
 	case float64:
 		return NewFloatValue(float64(v)), nil
+	case *big.Int:
+		if v == nil {
+			return NewNullValue(), nil
+		}
+		return o.BigNumbers.bigIntValue(v)
+	case *big.Float:
+		if v == nil {
+			return NewNullValue(), nil
+		}
+		return o.BigNumbers.bigFloatValue(v)
+	case json.Number:
+		if !isNumber(string(v)) {
+			return nil, protoimpl.X.NewError("invalid number: %q", v)
+		}
+		return o.BigNumbers.numberValue(string(v))
 	case string:
 		if !utf8.ValidString(v) {
 			return nil, protoimpl.X.NewError("invalid UTF-8 in string: %q", v)
 		}
 		return NewStringValue(v), nil
 	case []byte:
-		s := base64.StdEncoding.EncodeToString(v)
-		return NewStringValue(s), nil
+		return NewBytesValue(append([]byte{}, v...)), nil
+	case time.Time:
+		return newTimestampValue(v)
+	case *time.Time:
+		if v == nil {
+			return NewNullValue(), nil
+		}
+		return newTimestampValue(*v)
+	case time.Duration:
+		return NewDurationValue(v), nil
 	case map[string]interface{}:
-		v2, err := NewDict(v)
-		if err != nil {
-			return nil, err
+		x := &Dict{Fields: make(map[string]*Value, len(v))}
+		for k, e := range v {
+			if !utf8.ValidString(k) {
+				return nil, protoimpl.X.NewError("invalid UTF-8 in string: %q", k)
+			}
+			var err error
+			if x.Fields[k], err = o.NewValue(e); err != nil {
+				return nil, err
+			}
 		}
-		return NewStructValue(v2), nil
+		return NewStructValue(x), nil
 	case []interface{}:
-		v2, err := NewList(v)
-		if err != nil {
-			return nil, err
+		x := &List{Values: make([]*Value, len(v))}
+		for i, e := range v {
+			var err error
+			if x.Values[i], err = o.NewValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return NewListValue(x), nil
+	case *Value:
+		if v == nil {
+			return NewNullValue(), nil
+		}
+		return v.Clone(), nil
+	case *Dict:
+		if v == nil {
+			return NewNullValue(), nil
 		}
-		return NewListValue(v2), nil
+		return NewStructValue(v.Clone()), nil
+	case *List:
+		if v == nil {
+			return NewNullValue(), nil
+		}
+		return NewListValue(v.Clone()), nil
 	default:
-		return nil, protoimpl.X.NewError("invalid type: %T", v)
+		return o.newValueReflect(reflect.ValueOf(v))
 	}
 }
 
@@ -114,6 +198,40 @@ func NewStringValue(v string) *Value {
 	return &Value{Kind: &Value_StringValue{StringValue: v}}
 }
 
+// NewDecimalValue constructs a new arbitrary-precision number Value from
+// its JSON number representation, such as "18446744073709551615".
+func NewDecimalValue(v string) (*Value, error) {
+	if !isNumber(v) {
+		return nil, protoimpl.X.NewError("invalid number: %q", v)
+	}
+	return &Value{Kind: &Value_DecimalValue{DecimalValue: v}}, nil
+}
+
+// NewBytesValue constructs a new binary Value.
+func NewBytesValue(v []byte) *Value {
+	return &Value{Kind: &Value_BytesValue{BytesValue: v}}
+}
+
+// NewTimestampValue constructs a new timestamp Value.
+func NewTimestampValue(v time.Time) *Value {
+	return &Value{Kind: &Value_TimestampValue{TimestampValue: timestamppb.New(v)}}
+}
+
+// newTimestampValue is like NewTimestampValue, but reports times outside
+// of the range supported by Timestamp (years 1 to 9999) as an error.
+func newTimestampValue(v time.Time) (*Value, error) {
+	ts := timestamppb.New(v)
+	if err := ts.CheckValid(); err != nil {
+		return nil, protoimpl.X.NewError("invalid time %v: %v", v, err)
+	}
+	return &Value{Kind: &Value_TimestampValue{TimestampValue: ts}}, nil
+}
+
+// NewDurationValue constructs a new duration Value.
+func NewDurationValue(v time.Duration) *Value {
+	return &Value{Kind: &Value_DurationValue{DurationValue: durationpb.New(v)}}
+}
+
 // NewStructValue constructs a new struct Value.
 func NewStructValue(v *Dict) *Value {
 	return &Value{Kind: &Value_DictValue{DictValue: v}}
@@ -125,7 +243,8 @@ func NewListValue(v *List) *Value {
 }
 
 // Unwrap returns the underlying value
-// it's type may be nil, int64, float64, string, bool, *Dict, *List
+// it's type may be nil, int64, float64, json.Number, string, bool, []byte,
+// time.Time, time.Duration, *Dict, *List
 //
 // Call from a nil is safe
 func (x *Value) Unwrap() interface{} {
@@ -138,6 +257,10 @@ func (x *Value) Unwrap() interface{} {
 		if v != nil {
 			return v.FloatValue
 		}
+	case *Value_DecimalValue:
+		if v != nil {
+			return json.Number(v.DecimalValue)
+		}
 	case *Value_StringValue:
 		if v != nil {
 			return v.StringValue
@@ -146,6 +269,18 @@ func (x *Value) Unwrap() interface{} {
 		if v != nil {
 			return v.BoolValue
 		}
+	case *Value_BytesValue:
+		if v != nil {
+			return v.BytesValue
+		}
+	case *Value_TimestampValue:
+		if v != nil {
+			return v.TimestampValue.AsTime()
+		}
+	case *Value_DurationValue:
+		if v != nil {
+			return v.DurationValue.AsDuration()
+		}
 	case *Value_DictValue:
 		if v != nil {
 			return v.DictValue
@@ -165,6 +300,8 @@ func (x *Value) Unwrap() interface{} {
 //
 // For Null, Int, String, Bool, the return value is same as Unwrap (will return nil, int64, string, bool)
 // For Float, this may return a float64 or "NaN" "Infinity" "-Infinity" string
+// For Decimal, this will return a json.Number
+// For Bytes, Timestamp, Duration, this will return a []byte, time.Time, time.Duration
 // For Dict, this will return a map[string]interface{}, which is returned from (*Dict).AsMap()
 // For List, this will return a []interface{}, which is returned from (*List).AsSlice()
 //
@@ -188,6 +325,10 @@ func (x *Value) AsInterface() interface{} {
 				return v.FloatValue
 			}
 		}
+	case *Value_DecimalValue:
+		if v != nil {
+			return json.Number(v.DecimalValue)
+		}
 	case *Value_StringValue:
 		if v != nil {
 			return v.StringValue
@@ -196,6 +337,18 @@ func (x *Value) AsInterface() interface{} {
 		if v != nil {
 			return v.BoolValue
 		}
+	case *Value_BytesValue:
+		if v != nil {
+			return v.BytesValue
+		}
+	case *Value_TimestampValue:
+		if v != nil {
+			return v.TimestampValue.AsTime()
+		}
+	case *Value_DurationValue:
+		if v != nil {
+			return v.DurationValue.AsDuration()
+		}
 	case *Value_DictValue:
 		if v != nil {
 			return v.DictValue.AsMap()
@@ -207,3 +360,55 @@ func (x *Value) AsInterface() interface{} {
 	}
 	return nil
 }
+
+// toInterface is like AsInterface, but keeps non-finite floats as float64
+func (x *Value) toInterface() interface{} {
+	switch v := x.GetKind().(type) {
+	case *Value_FloatValue:
+		return v.FloatValue
+	case *Value_DictValue:
+		m := make(map[string]interface{}, len(v.DictValue.GetFields()))
+		for k, e := range v.DictValue.GetFields() {
+			m[k] = e.toInterface()
+		}
+		return m
+	case *Value_ListValue:
+		s := make([]interface{}, len(v.ListValue.GetValues()))
+		for i, e := range v.ListValue.GetValues() {
+			s[i] = e.toInterface()
+		}
+		return s
+	default:
+		return x.Unwrap()
+	}
+}
+
+// kindName returns a human readable name of the kind of x
+func kindName(x *Value) string {
+	switch x.GetKind().(type) {
+	case *Value_NullValue:
+		return "NullValue"
+	case *Value_IntValue:
+		return "IntValue"
+	case *Value_FloatValue:
+		return "FloatValue"
+	case *Value_DecimalValue:
+		return "DecimalValue"
+	case *Value_StringValue:
+		return "StringValue"
+	case *Value_BoolValue:
+		return "BoolValue"
+	case *Value_BytesValue:
+		return "BytesValue"
+	case *Value_TimestampValue:
+		return "TimestampValue"
+	case *Value_DurationValue:
+		return "DurationValue"
+	case *Value_DictValue:
+		return "DictValue"
+	case *Value_ListValue:
+		return "ListValue"
+	default:
+		return "<nil>"
+	}
+}
@@ -0,0 +1,107 @@
+package structpb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBinaryAndTimeKinds(t *testing.T) {
+	ts := time.Date(2021, 6, 7, 8, 9, 10, 123000000, time.FixedZone("X", 3600))
+	tests := []struct {
+		name   string
+		in     *Value
+		kind   string
+		json   string
+		unwrap interface{}
+	}{
+		{"bytes", NewBytesValue([]byte{0, 1, 0xfe}), "BytesValue", `"AAH+"`, nil},
+		{"timestamp", NewTimestampValue(ts), "TimestampValue", `"2021-06-07T07:09:10.123Z"`, ts.UTC()},
+		{"duration", NewDurationValue(-90 * time.Second), "DurationValue", `"-90s"`, -90 * time.Second},
+		{"duration nanos", NewDurationValue(time.Nanosecond), "DurationValue", `"0.000000001s"`, time.Nanosecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kindName(tt.in); got != tt.kind {
+				t.Errorf("kind = %s, want %s", got, tt.kind)
+			}
+			if got := toJSON(t, tt.in); got != tt.json {
+				t.Errorf("Marshal = %s, want %s", got, tt.json)
+			}
+			if tt.unwrap != nil {
+				if got := tt.in.Unwrap(); got != tt.unwrap {
+					t.Errorf("Unwrap = %v, want %v", got, tt.unwrap)
+				}
+			}
+
+			// the kinds survive the protobuf wire format
+			b, err := proto.Marshal(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := &Value{}
+			if err := proto.Unmarshal(b, out); err != nil {
+				t.Fatal(err)
+			}
+			if !Equal(out, tt.in) {
+				t.Errorf("wire round trip = %v, want %v", out, tt.in)
+			}
+
+			// JSON has no such kinds, they are read back as strings
+			if got := kindName(mustParse(t, tt.json)); got != "StringValue" {
+				t.Errorf("Unmarshal(%s) = %s, want StringValue", tt.json, got)
+			}
+		})
+	}
+	if got := NewBytesValue([]byte("x")).Unwrap().([]byte); !bytes.Equal(got, []byte("x")) {
+		t.Errorf("Unwrap of bytes = %q", got)
+	}
+}
+
+func TestNewValueKinds(t *testing.T) {
+	ts := time.Unix(1600000000, 5).UTC()
+	v, err := NewValue(map[string]interface{}{
+		"b": []byte("x"),
+		"t": ts,
+		"d": time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := v.GetDictValue()
+	if !bytes.Equal(d.Fields["b"].GetBytesValue(), []byte("x")) {
+		t.Errorf("bytes = %v", d.Fields["b"])
+	}
+	if got := d.Fields["t"].GetTimestampValue().AsTime(); !got.Equal(ts) {
+		t.Errorf("timestamp = %v, want %v", got, ts)
+	}
+	if got := d.Fields["d"].GetDurationValue().AsDuration(); got != time.Minute {
+		t.Errorf("duration = %v", got)
+	}
+
+	// time.Time before year 1 is out of the range of Timestamp
+	if _, err := NewValue(time.Date(-1, 1, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("NewValue of a time before year 1 succeeded")
+	}
+}
+
+func TestDecodeKinds(t *testing.T) {
+	var out struct {
+		B []byte        `json:"b"`
+		T time.Time     `json:"t"`
+		D time.Duration `json:"d"`
+	}
+	ts := time.Unix(1600000000, 0).UTC()
+	d := NewEmptyDict()
+	d.Set("b", NewBytesValue([]byte("x")))
+	d.Set("t", NewTimestampValue(ts))
+	d.Set("d", NewDurationValue(time.Second))
+	if err := d.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if string(out.B) != "x" || !out.T.Equal(ts) || out.D != time.Second {
+		t.Errorf("Decode = %+v", out)
+	}
+}
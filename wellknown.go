@@ -0,0 +1,366 @@
+package structpb
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	wkpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// InexactNumberPolicy defines how numbers that google.protobuf.Value cannot
+// hold exactly are converted by ToWellKnown: IntValues outside of ±2^53
+// which are not exact float64, and DecimalValues which are not exact float64.
+type InexactNumberPolicy int
+
+const (
+	// InexactError reports an error. This is the default.
+	InexactError InexactNumberPolicy = iota
+	// InexactString stores their decimal representation as string_value.
+	InexactString
+	// InexactRound stores them as number_value, rounded to the nearest
+	// float64.
+	InexactRound
+)
+
+// WellKnownOptions configures the conversion between Value and the
+// google.protobuf.Value, Struct and ListValue well-known types.
+type WellKnownOptions struct {
+	// InexactNumbers selects how numbers that cannot be stored exactly as
+	// a number_value are converted by ToWellKnown, see InexactNumberPolicy.
+	InexactNumbers InexactNumberPolicy
+
+	// DetectInts makes FromWellKnown store integral number_values in the
+	// int64 range as IntValue rather than FloatValue.
+	DetectInts bool
+}
+
+// A WellKnownError describes a value that could not be converted to a
+// well-known type.
+type WellKnownError struct {
+	// Path is the JSON Pointer of the offending value, "" is the root.
+	Path string
+	Err  error
+}
+
+func (e *WellKnownError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "root"
+	}
+	return fmt.Sprintf("cannot convert %s to well-known type: %v", path, e.Err)
+}
+
+func (e *WellKnownError) Unwrap() error { return e.Err }
+
+// ToWellKnown converts x to a google.protobuf.Value, see
+// WellKnownOptions.ToWellKnown.
+func ToWellKnown(x *Value) (*wkpb.Value, error) {
+	return WellKnownOptions{}.ToWellKnown(x)
+}
+
+// FromWellKnown converts a google.protobuf.Value to a Value, see
+// WellKnownOptions.FromWellKnown.
+func FromWellKnown(x *wkpb.Value) *Value {
+	return WellKnownOptions{}.FromWellKnown(x)
+}
+
+// ToWellKnownStruct converts x to a google.protobuf.Struct, see
+// WellKnownOptions.ToWellKnown.
+func ToWellKnownStruct(x *Dict) (*wkpb.Struct, error) {
+	return WellKnownOptions{}.ToWellKnownStruct(x)
+}
+
+// FromWellKnownStruct converts a google.protobuf.Struct to a Dict, see
+// WellKnownOptions.FromWellKnown.
+func FromWellKnownStruct(x *wkpb.Struct) *Dict {
+	return WellKnownOptions{}.FromWellKnownStruct(x)
+}
+
+// ToWellKnownList converts x to a google.protobuf.ListValue, see
+// WellKnownOptions.ToWellKnown.
+func ToWellKnownList(x *List) (*wkpb.ListValue, error) {
+	return WellKnownOptions{}.ToWellKnownList(x)
+}
+
+// FromWellKnownList converts a google.protobuf.ListValue to a List, see
+// WellKnownOptions.FromWellKnown.
+func FromWellKnownList(x *wkpb.ListValue) *List {
+	return WellKnownOptions{}.FromWellKnownList(x)
+}
+
+// ToWellKnown converts x to a google.protobuf.Value.
+//
+// The kinds google.protobuf.Value lacks are converted like in JSON format:
+// IntValue and DecimalValue are stored as number_value when exact, and
+// according to InexactNumbers otherwise; BytesValue is stored as a base64
+// string_value; TimestampValue and DurationValue as the string_value of
+// their JSON mapping. The key order of ordered Dicts is not kept.
+//
+// A nil Value is converted to null. Errors are reported as a
+// *WellKnownError.
+func (o WellKnownOptions) ToWellKnown(x *Value) (*wkpb.Value, error) {
+	return o.toWellKnown(x, "")
+}
+
+// ToWellKnownStruct converts x to a google.protobuf.Struct, see ToWellKnown.
+func (o WellKnownOptions) ToWellKnownStruct(x *Dict) (*wkpb.Struct, error) {
+	return o.toWellKnownStruct(x, "")
+}
+
+// ToWellKnownList converts x to a google.protobuf.ListValue, see ToWellKnown.
+func (o WellKnownOptions) ToWellKnownList(x *List) (*wkpb.ListValue, error) {
+	return o.toWellKnownList(x, "")
+}
+
+func (o WellKnownOptions) toWellKnown(x *Value, path string) (*wkpb.Value, error) {
+	switch v := x.GetKind().(type) {
+	case *Value_BoolValue:
+		return wkpb.NewBoolValue(v.BoolValue), nil
+	case *Value_IntValue:
+		f := float64(v.IntValue)
+		if f < math.MaxInt64 && int64(f) == v.IntValue {
+			return wkpb.NewNumberValue(f), nil
+		}
+		return o.inexactNumber(strconv.FormatInt(v.IntValue, 10), f, path)
+	case *Value_FloatValue:
+		return wkpb.NewNumberValue(v.FloatValue), nil
+	case *Value_DecimalValue:
+		r, ok := new(big.Rat).SetString(v.DecimalValue)
+		if !ok {
+			return nil, &WellKnownError{Path: path, Err: fmt.Errorf("invalid decimal %q", v.DecimalValue)}
+		}
+		f, exact := r.Float64()
+		if exact {
+			return wkpb.NewNumberValue(f), nil
+		}
+		return o.inexactNumber(v.DecimalValue, f, path)
+	case *Value_StringValue:
+		return wkpb.NewStringValue(v.StringValue), nil
+	case *Value_BytesValue:
+		return wkpb.NewStringValue(base64.StdEncoding.EncodeToString(v.BytesValue)), nil
+	case *Value_TimestampValue:
+		return wkpb.NewStringValue(formatTimestamp(v.TimestampValue)), nil
+	case *Value_DurationValue:
+		return wkpb.NewStringValue(formatDuration(v.DurationValue)), nil
+	case *Value_DictValue:
+		s, err := o.toWellKnownStruct(v.DictValue, path)
+		if err != nil {
+			return nil, err
+		}
+		return wkpb.NewStructValue(s), nil
+	case *Value_ListValue:
+		l, err := o.toWellKnownList(v.ListValue, path)
+		if err != nil {
+			return nil, err
+		}
+		return wkpb.NewListValue(l), nil
+	default:
+		return wkpb.NewNullValue(), nil
+	}
+}
+
+func (o WellKnownOptions) toWellKnownStruct(x *Dict, path string) (*wkpb.Struct, error) {
+	s := &wkpb.Struct{Fields: make(map[string]*wkpb.Value, len(x.GetFields()))}
+	for k, v := range x.GetFields() {
+		w, err := o.toWellKnown(v, appendPointer(path, k))
+		if err != nil {
+			return nil, err
+		}
+		s.Fields[k] = w
+	}
+	return s, nil
+}
+
+func (o WellKnownOptions) toWellKnownList(x *List, path string) (*wkpb.ListValue, error) {
+	l := &wkpb.ListValue{Values: make([]*wkpb.Value, len(x.GetValues()))}
+	for i, v := range x.GetValues() {
+		w, err := o.toWellKnown(v, appendPointer(path, strconv.Itoa(i)))
+		if err != nil {
+			return nil, err
+		}
+		l.Values[i] = w
+	}
+	return l, nil
+}
+
+// inexactNumber converts the number s, whose nearest float64 is f,
+// according to InexactNumbers.
+func (o WellKnownOptions) inexactNumber(s string, f float64, path string) (*wkpb.Value, error) {
+	switch o.InexactNumbers {
+	case InexactString:
+		return wkpb.NewStringValue(s), nil
+	case InexactRound:
+		return wkpb.NewNumberValue(f), nil
+	default:
+		return nil, &WellKnownError{Path: path, Err: fmt.Errorf("number %s cannot be represented exactly as a double", s)}
+	}
+}
+
+// FromWellKnown converts a google.protobuf.Value to a Value.
+//
+// A number_value is stored as FloatValue, or as IntValue if DetectInts is
+// set and it is an integer in the int64 range other than -0. A nil Value,
+// or one with no kind set, is converted to null.
+func (o WellKnownOptions) FromWellKnown(x *wkpb.Value) *Value {
+	switch v := x.GetKind().(type) {
+	case *wkpb.Value_BoolValue:
+		return NewBoolValue(v.BoolValue)
+	case *wkpb.Value_NumberValue:
+		f := v.NumberValue
+		if o.DetectInts && isIntFloat(f) {
+			return NewIntValue(int64(f))
+		}
+		return NewFloatValue(f)
+	case *wkpb.Value_StringValue:
+		return NewStringValue(v.StringValue)
+	case *wkpb.Value_StructValue:
+		return NewStructValue(o.FromWellKnownStruct(v.StructValue))
+	case *wkpb.Value_ListValue:
+		return NewListValue(o.FromWellKnownList(v.ListValue))
+	default:
+		return NewNullValue()
+	}
+}
+
+// FromWellKnownStruct converts a google.protobuf.Struct to a Dict, see
+// FromWellKnown.
+func (o WellKnownOptions) FromWellKnownStruct(x *wkpb.Struct) *Dict {
+	d := &Dict{Fields: make(map[string]*Value, len(x.GetFields()))}
+	for k, v := range x.GetFields() {
+		d.Fields[k] = o.FromWellKnown(v)
+	}
+	return d
+}
+
+// FromWellKnownList converts a google.protobuf.ListValue to a List, see
+// FromWellKnown.
+func (o WellKnownOptions) FromWellKnownList(x *wkpb.ListValue) *List {
+	l := &List{Values: make([]*Value, len(x.GetValues()))}
+	for i, v := range x.GetValues() {
+		l.Values[i] = o.FromWellKnown(v)
+	}
+	return l
+}
+
+// UnmarshalWellKnownStruct parses the wire-format encoding of a
+// google.protobuf.Struct into a Dict, see
+// WellKnownOptions.UnmarshalWellKnownStruct.
+func UnmarshalWellKnownStruct(b []byte) (*Dict, error) {
+	return WellKnownOptions{}.UnmarshalWellKnownStruct(b)
+}
+
+// MarshalWellKnownStruct returns the wire-format encoding of x as a
+// google.protobuf.Struct, see WellKnownOptions.MarshalWellKnownStruct.
+func MarshalWellKnownStruct(x *Dict) ([]byte, error) {
+	return WellKnownOptions{}.MarshalWellKnownStruct(x)
+}
+
+// UnmarshalWellKnownStruct parses the wire-format encoding of a
+// google.protobuf.Struct into a Dict, without going through the
+// well-known types.
+//
+// This relies on Dict, List and Value using the same field numbers and
+// wire types as Struct, ListValue and Value for the fields they share:
+// Struct.fields is Dict.fields (1), ListValue.values is List.values (1),
+// and Value.null_value, number_value, string_value, bool_value,
+// struct_value and list_value are Value.null_value (1), float_value (2),
+// string_value (3), bool_value (4), dict_value (5) and list_value (6).
+// So the result is the same as FromWellKnownStruct applied to the parsed
+// Struct, including the promotion of integral numbers if DetectInts is set.
+func (o WellKnownOptions) UnmarshalWellKnownStruct(b []byte) (*Dict, error) {
+	x := &Dict{}
+	if err := proto.Unmarshal(b, x); err != nil {
+		return nil, err
+	}
+	if o.DetectInts {
+		detectIntsDict(x)
+	}
+	return x, nil
+}
+
+// MarshalWellKnownStruct returns the wire-format encoding of x as a
+// google.protobuf.Struct, which can be parsed by any implementation of the
+// well-known type.
+//
+// When x only holds the kinds shared with google.protobuf.Value (null,
+// float, string, bool, Dict and List) and no ordered Dict, it is encoded
+// directly, see UnmarshalWellKnownStruct. Otherwise it is converted first,
+// as by ToWellKnownStruct.
+func (o WellKnownOptions) MarshalWellKnownStruct(x *Dict) ([]byte, error) {
+	if isWellKnownDict(x) {
+		return proto.Marshal(x)
+	}
+	s, err := o.ToWellKnownStruct(x)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(s)
+}
+
+// isWellKnownDict reports whether the encoding of x is also a valid
+// encoding of the same google.protobuf.Struct.
+func isWellKnownDict(x *Dict) bool {
+	if x.GetOrdered() || len(x.GetKeyOrder()) > 0 {
+		return false
+	}
+	for _, v := range x.GetFields() {
+		if !isWellKnownValue(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWellKnownValue(x *Value) bool {
+	switch v := x.GetKind().(type) {
+	case *Value_NullValue, *Value_FloatValue, *Value_StringValue, *Value_BoolValue:
+		return true
+	case *Value_DictValue:
+		return isWellKnownDict(v.DictValue)
+	case *Value_ListValue:
+		for _, e := range v.ListValue.GetValues() {
+			if !isWellKnownValue(e) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// isIntFloat reports whether f is an integer in the int64 range that
+// DetectInts stores as IntValue. -0 is not, as its sign would be lost.
+func isIntFloat(f float64) bool {
+	if f == 0 {
+		return !math.Signbit(f)
+	}
+	return f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64
+}
+
+// detectIntsValue stores integral FloatValues in the int64 range as IntValue,
+// recursively, like FromWellKnown with DetectInts.
+func detectIntsValue(x *Value) {
+	switch v := x.GetKind().(type) {
+	case *Value_FloatValue:
+		if f := v.FloatValue; isIntFloat(f) {
+			x.Kind = &Value_IntValue{IntValue: int64(f)}
+		}
+	case *Value_DictValue:
+		detectIntsDict(v.DictValue)
+	case *Value_ListValue:
+		for _, e := range v.ListValue.GetValues() {
+			detectIntsValue(e)
+		}
+	}
+}
+
+func detectIntsDict(x *Dict) {
+	for _, v := range x.GetFields() {
+		detectIntsValue(v)
+	}
+}
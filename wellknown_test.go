@@ -0,0 +1,237 @@
+package structpb
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	wkpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// wellKnownJSON encodes the well-known m with protojson in a stable form.
+func wellKnownJSON(t *testing.T, m proto.Message) string {
+	t.Helper()
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// protojson randomizes whitespace, go through a Value to normalize it
+	return toJSON(t, mustParse(t, string(b)))
+}
+
+func TestToWellKnown(t *testing.T) {
+	dec, _ := NewDecimalValue("0.5")
+	tests := []struct {
+		name string
+		in   *Value
+		want string
+	}{
+		{"nil", nil, `null`},
+		{"int", NewIntValue(-42), `-42`},
+		{"max exact int", NewIntValue(1 << 53), `9007199254740992`},
+		{"large exact int", NewIntValue(1 << 62), `4611686018427388000`},
+		{"exact decimal", dec, `0.5`},
+		{"bytes", NewBytesValue([]byte{0, 0xff}), `"AP8="`},
+		{"timestamp", NewTimestampValue(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)), `"2021-01-02T03:04:05Z"`},
+		{"duration", NewDurationValue(1500 * time.Millisecond), `"1.500s"`},
+		{"tree", mustParse(t, `{"a":[true,"s",null,{}],"b":1.5}`), `{"a":[true,"s",null,{}],"b":1.5}`},
+	}
+	for _, tt := range tests {
+		w, err := ToWellKnown(tt.in)
+		if err != nil {
+			t.Errorf("%s: ToWellKnown: %v", tt.name, err)
+			continue
+		}
+		if got := wellKnownJSON(t, w); got != tt.want {
+			t.Errorf("%s: ToWellKnown = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestToWellKnownInexact(t *testing.T) {
+	dec, _ := NewDecimalValue("0.1")
+	huge, _ := NewDecimalValue("18446744073709551617")
+	tests := []struct {
+		in     *Value
+		policy InexactNumberPolicy
+		want   *wkpb.Value
+	}{
+		{NewIntValue(1<<53 + 1), InexactString, wkpb.NewStringValue("9007199254740993")},
+		{NewIntValue(1<<53 + 1), InexactRound, wkpb.NewNumberValue(1 << 53)},
+		{NewIntValue(math.MaxInt64), InexactString, wkpb.NewStringValue("9223372036854775807")},
+		{NewIntValue(math.MaxInt64), InexactRound, wkpb.NewNumberValue(1 << 63)},
+		{dec, InexactString, wkpb.NewStringValue("0.1")},
+		{dec, InexactRound, wkpb.NewNumberValue(0.1)},
+		{huge, InexactRound, wkpb.NewNumberValue(1 << 64)},
+	}
+	for _, tt := range tests {
+		got, err := WellKnownOptions{InexactNumbers: tt.policy}.ToWellKnown(tt.in)
+		if err != nil {
+			t.Errorf("ToWellKnown(%v) with policy %d: %v", tt.in, tt.policy, err)
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("ToWellKnown(%v) with policy %d = %v, want %v", tt.in, tt.policy, got, tt.want)
+		}
+
+		_, err = ToWellKnown(tt.in)
+		if err == nil {
+			t.Errorf("ToWellKnown(%v) with InexactError succeeded", tt.in)
+		}
+	}
+
+	_, err := ToWellKnownStruct(mustParse(t, `{"a":[0,{"b":9007199254740993}]}`).GetDictValue())
+	var we *WellKnownError
+	if !errors.As(err, &we) || we.Path != "/a/1/b" {
+		t.Errorf("ToWellKnownStruct error = %v, want a *WellKnownError at /a/1/b", err)
+	}
+}
+
+func TestFromWellKnown(t *testing.T) {
+	w, err := wkpb.NewValue(map[string]interface{}{
+		"int":   3.0,
+		"frac":  2.5,
+		"neg0":  math.Copysign(0, -1),
+		"huge":  1e300,
+		"list":  []interface{}{1.0, "s", true, nil},
+		"inner": map[string]interface{}{"x": -7.0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := FromWellKnown(w)
+	for _, k := range []string{"int", "frac", "neg0", "huge"} {
+		if got := kindName(x.GetDictValue().Fields[k]); got != "FloatValue" {
+			t.Errorf("FromWellKnown %s = %s, want FloatValue", k, got)
+		}
+	}
+
+	x = WellKnownOptions{DetectInts: true}.FromWellKnown(w)
+	kinds := map[string]string{"int": "IntValue", "frac": "FloatValue", "neg0": "FloatValue", "huge": "FloatValue"}
+	for k, want := range kinds {
+		if got := kindName(x.GetDictValue().Fields[k]); got != want {
+			t.Errorf("FromWellKnown with DetectInts %s = %s, want %s", k, got, want)
+		}
+	}
+	if got := kindName(x.GetDictValue().Fields["list"].GetListValue().Values[0]); got != "IntValue" {
+		t.Errorf("FromWellKnown with DetectInts list element = %s, want IntValue", got)
+	}
+	if got := kindName(x.GetDictValue().Fields["inner"].GetDictValue().Fields["x"]); got != "IntValue" {
+		t.Errorf("FromWellKnown with DetectInts nested = %s, want IntValue", got)
+	}
+
+	if got := kindName(FromWellKnown(nil)); got != "NullValue" {
+		t.Errorf("FromWellKnown(nil) = %s, want NullValue", got)
+	}
+	if got := kindName(FromWellKnown(&wkpb.Value{})); got != "NullValue" {
+		t.Errorf("FromWellKnown of an empty Value = %s, want NullValue", got)
+	}
+}
+
+func TestWellKnownRoundTrip(t *testing.T) {
+	in := mustParse(t, `{"a":[1,2.5,"s",true,null,{"b":[]}],"c":-3}`)
+	w, err := ToWellKnown(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := WellKnownOptions{DetectInts: true}.FromWellKnown(w)
+	if !Equal(in, out) {
+		t.Errorf("round trip = %s, want %s", toJSON(t, out), toJSON(t, in))
+	}
+
+	l, err := ToWellKnownList(in.GetDictValue().Fields["a"].GetListValue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toJSON(t, FromWellKnownList(l)); got != `[1,2.5,"s",true,null,{"b":[]}]` {
+		t.Errorf("list round trip = %s", got)
+	}
+}
+
+func TestUnmarshalWellKnownStruct(t *testing.T) {
+	s, err := wkpb.NewStruct(map[string]interface{}{
+		"n":    2.0,
+		"f":    0.5,
+		"s":    "x",
+		"b":    false,
+		"null": nil,
+		"list": []interface{}{1.0, map[string]interface{}{"k": 3.0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := proto.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := UnmarshalWellKnownStruct(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(NewStructValue(d), NewStructValue(FromWellKnownStruct(s))) {
+		t.Errorf("UnmarshalWellKnownStruct = %s, want %s", toJSON(t, d), toJSON(t, FromWellKnownStruct(s)))
+	}
+	if got := kindName(d.Fields["n"]); got != "FloatValue" {
+		t.Errorf("UnmarshalWellKnownStruct n = %s, want FloatValue", got)
+	}
+
+	o := WellKnownOptions{DetectInts: true}
+	d, err = o.UnmarshalWellKnownStruct(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(NewStructValue(d), NewStructValue(o.FromWellKnownStruct(s))) {
+		t.Errorf("UnmarshalWellKnownStruct with DetectInts = %s, want %s", toJSON(t, d), toJSON(t, o.FromWellKnownStruct(s)))
+	}
+	if got := kindName(d.Fields["list"].GetListValue().Values[1].GetDictValue().Fields["k"]); got != "IntValue" {
+		t.Errorf("UnmarshalWellKnownStruct with DetectInts nested = %s, want IntValue", got)
+	}
+
+	if _, err := UnmarshalWellKnownStruct([]byte{0x0a, 0xff}); err == nil {
+		t.Error("UnmarshalWellKnownStruct of truncated bytes succeeded")
+	}
+}
+
+func TestMarshalWellKnownStruct(t *testing.T) {
+	ordered := NewOrderedDict()
+	ordered.Set("z", NewFloatValue(1))
+	tests := []struct {
+		name string
+		in   *Dict
+		want string
+	}{
+		{"shared kinds", mustParse(t, `{"a":[1.5,"s",true,null,{"b":{}}]}`).GetDictValue(), `{"a":[1.5,"s",true,null,{"b":{}}]}`},
+		{"ints", mustParse(t, `{"a":[1,{"b":-2}]}`).GetDictValue(), `{"a":[1,{"b":-2}]}`},
+		{"bytes", &Dict{Fields: map[string]*Value{"b": NewBytesValue([]byte("hi"))}}, `{"b":"aGk="}`},
+		{"ordered", ordered, `{"z":1}`},
+	}
+	for _, tt := range tests {
+		b, err := MarshalWellKnownStruct(tt.in)
+		if err != nil {
+			t.Errorf("%s: MarshalWellKnownStruct: %v", tt.name, err)
+			continue
+		}
+		s := &wkpb.Struct{}
+		if err := proto.Unmarshal(b, s); err != nil {
+			t.Errorf("%s: parsing as google.protobuf.Struct: %v", tt.name, err)
+			continue
+		}
+		if len(s.ProtoReflect().GetUnknown()) > 0 {
+			t.Errorf("%s: google.protobuf.Struct has unknown fields", tt.name)
+		}
+		if got := wellKnownJSON(t, s); got != tt.want {
+			t.Errorf("%s: MarshalWellKnownStruct = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	_, err := MarshalWellKnownStruct(&Dict{Fields: map[string]*Value{"i": NewIntValue(1<<53 + 1)}})
+	var we *WellKnownError
+	if !errors.As(err, &we) || we.Path != "/i" {
+		t.Errorf("MarshalWellKnownStruct of an inexact int = %v, want a *WellKnownError at /i", err)
+	}
+}